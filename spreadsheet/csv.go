@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func (server *server) getTableCSV(res http.ResponseWriter, req *http.Request) {
+	server.mut.RLock()
+	defer server.mut.RUnlock()
+
+	sheet, err := server.sheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	formulas := false
+	if value := req.URL.Query().Get("formulas"); value != "" {
+		formulas, err = strconv.ParseBool(value)
+		if err != nil {
+			http.Error(res, "failed to parse formulas: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	var buf bytes.Buffer
+	if err := sheet.WriteCSV(&buf, formulas); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h := res.Header()
+	h.Set("content-type", "text/csv; charset=utf-8")
+	h.Set("content-length", strconv.Itoa(buf.Len()))
+	res.WriteHeader(http.StatusOK)
+	_, _ = res.Write(buf.Bytes())
+}
+
+// postTableCSV replaces the table with an uploaded CSV file.
+func (server *server) postTableCSV(res http.ResponseWriter, req *http.Request) {
+	body, err := uploadedFile(res, req, "table.csv")
+	if err != nil {
+		http.Error(res, err.Error(), uploadErrorStatus(err))
+		return
+	}
+	table, err := engine.ReadTableCSV(body)
+	if err != nil {
+		http.Error(res, err.Error(), uploadErrorStatus(err))
+		return
+	}
+	server.mut.Lock()
+	defer server.mut.Unlock()
+	sheet, err := server.sheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	server.history.record(server.workbook.ReplaceSheet(sheet, &table))
+	server.autosave()
+
+	server.renderTable(res, req, sheet)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func TestServer_tableCSV_roundTrip(t *testing.T) {
+	s := newTestServer(2, 2)
+	patchCells(t, s, map[string]string{"A0": "3", "B0": "A0 ^ 2", "A1": `"note"`})
+
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/table.csv?formulas=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	formulas := rec.Body.String()
+
+	for query, status := range map[string]int{"formulas=0": http.StatusOK, "formulas=yes": http.StatusBadRequest} {
+		rec := httptest.NewRecorder()
+		s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/table.csv?"+query, nil))
+		if rec.Code != status {
+			t.Errorf("expected %s to be %d got %d: %s", query, status, rec.Code, rec.Body.String())
+		}
+		if status == http.StatusOK && strings.Contains(rec.Body.String(), "=A0") {
+			t.Errorf("expected %s to download the values got %s", query, rec.Body.String())
+		}
+	}
+
+	loaded := newTestServer(1, 1)
+	rec = httptest.NewRecorder()
+	loaded.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/table.csv", strings.NewReader(formulas)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := loaded.workbook.Sheets()[0].Cell(1, 0).Value; got != engine.NewNumber(9) {
+		t.Errorf("expected B0 to be 9 got %s", got)
+	}
+	if got := loaded.workbook.Sheets()[0].Cell(0, 1).Value; got != engine.Text("note") {
+		t.Errorf("expected A1 to be note got %s", got)
+	}
+}
+
+func TestServer_postTableCSV_multipart(t *testing.T) {
+	// larger than the memory limit used when parsing the table.json form
+	var file strings.Builder
+	for range 2000 {
+		file.WriteString("1,2,3,=A0 + 1\n")
+	}
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	w, err := form.CreateFormFile("table.csv", "table.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte(file.String()))
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(1, 1)
+	req := httptest.NewRequest(http.MethodPost, "/table.csv", &body)
+	req.Header.Set("content-type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if s.workbook.Sheets()[0].RowCount != 2000 || s.workbook.Sheets()[0].ColumnCount != 4 {
+		t.Errorf("expected a 4 by 2000 table got %d by %d", s.workbook.Sheets()[0].ColumnCount, s.workbook.Sheets()[0].RowCount)
+	}
+}
+
+func TestServer_postTableCSV_error(t *testing.T) {
+	s := newTestServer(1, 1)
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/table.csv", strings.NewReader("1,=NOPE(1)\n")))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unexpected status %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "row 0 column B") {
+		t.Errorf("expected the error to name the position got %s", rec.Body.String())
+	}
+}
+
+func TestServer_postTableCSV_tooLarge(t *testing.T) {
+	s := newTestServer(1, 1)
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/table.csv", strings.NewReader(strings.Repeat("1", maxUploadSize+1))))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("unexpected status %d", rec.Code)
+	}
+	if s.workbook.Sheets()[0].ColumnCount != 1 {
+		t.Errorf("expected the table to be unchanged")
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func (server *server) postIteration(res http.ResponseWriter, req *http.Request) {
+	server.mut.Lock()
+	defer server.mut.Unlock()
+
+	if err := req.ParseForm(); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sheet, err := server.formSheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	iteration := engine.Iteration{Enabled: req.Form.Get("enabled") != ""}
+	iteration.MaxIterations, err = strconv.Atoi(req.Form.Get("max-iterations"))
+	if err != nil {
+		http.Error(res, fmt.Sprintf("failed to parse the maximum number of iterations: %s", err), http.StatusBadRequest)
+		return
+	}
+	iteration.MaxChange, err = engine.ParseNumber(req.Form.Get("max-change"))
+	if err != nil {
+		http.Error(res, fmt.Sprintf("failed to parse the maximum change: %s", err), http.StatusBadRequest)
+		return
+	}
+	if err := server.workbook.SetIteration(iteration); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	server.publishSheets(req)
+	server.autosave()
+	redirect(res, req, sheetPath(sheet))
+}
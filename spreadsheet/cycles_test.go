@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func TestServer_postIteration(t *testing.T) {
+	s := newTestServer(3, 3)
+	patchCells(t, s, map[string]string{"A0": "100", "B0": "A0 + B1", "B1": "B0 * 0.5", "C0": "B0 * 2"})
+	sheet := s.workbook.Sheets()[0]
+	if got := sheet.Cell(1, 0).ErrorKind(); got != string(engine.ErrorCycle) {
+		t.Fatalf("expected a cycle without iterative calculation got %q", got)
+	}
+
+	setIteration := func(form url.Values) {
+		t.Helper()
+		if rec := serveForm(t, s, http.MethodPost, "/iteration", form); rec.Code != http.StatusSeeOther {
+			t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	setIteration(url.Values{"enabled": {"on"}, "max-iterations": {"100"}, "max-change": {"0.001"}})
+
+	// B0 = 100 + B0 / 2 settles at 200
+	tolerance, _ := engine.ParseNumber("0.01")
+	for _, tt := range []struct {
+		Column, Row int
+		Want        string
+	}{
+		{Column: 1, Row: 0, Want: "200"},
+		{Column: 1, Row: 1, Want: "100"},
+		{Column: 2, Row: 0, Want: "400"},
+	} {
+		got, ok := sheet.Cell(tt.Column, tt.Row).Value.(engine.Number)
+		want, _ := engine.ParseNumber(tt.Want)
+		if !ok || !near(want, got, tolerance) {
+			t.Errorf("expected %s to settle near %s got %v", sheet.Cell(tt.Column, tt.Row).Label(), tt.Want, sheet.Cell(tt.Column, tt.Row).Value)
+		}
+	}
+
+	// an edit is calculated iteratively as well
+	patchCells(t, s, map[string]string{"A0": "50"})
+	if got, _ := sheet.Cell(1, 0).Value.(engine.Number); !near(engine.NewNumber(100), got, tolerance) {
+		t.Errorf("expected B0 to settle near 100 got %s", got)
+	}
+
+	setIteration(url.Values{"enabled": {"on"}, "max-iterations": {"1"}, "max-change": {"0.001"}})
+	if got := sheet.Cell(1, 0).String(); got != "50" {
+		t.Errorf("expected one iteration to stop at 50 got %s", got)
+	}
+
+	buf, err := json.Marshal(s.workbook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(buf), `"iteration":{"max_iterations":1,"max_change":"0.001"}`) {
+		t.Errorf("expected the settings to be saved: %s", buf)
+	}
+	loaded, err := engine.ParseWorkbook(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Iteration(); !got.Enabled || got.MaxIterations != 1 {
+		t.Errorf("expected the settings to be loaded got %+v", got)
+	}
+
+	setIteration(url.Values{"max-iterations": {"100"}, "max-change": {"0.001"}})
+	if got := sheet.Cell(1, 0).ErrorKind(); got != string(engine.ErrorCycle) {
+		t.Errorf("expected the cycle error after disabling iterative calculation got %q", got)
+	}
+
+	for _, form := range []url.Values{
+		{"max-iterations": {"0"}, "max-change": {"0.001"}},
+		{"max-iterations": {"x"}, "max-change": {"0.001"}},
+		{"max-iterations": {"10"}, "max-change": {"-1"}},
+		{"max-iterations": {"10"}, "max-change": {""}},
+	} {
+		if rec := serveForm(t, s, http.MethodPost, "/iteration", form); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected %v to be a bad request got %d", form, rec.Code)
+		}
+	}
+}
+
+// near reports whether got is at most tolerance away from want.
+func near(want, got, tolerance engine.Number) bool {
+	difference, err := got.Sub(want)
+	if err != nil {
+		return false
+	}
+	if difference.Sign() < 0 {
+		difference = difference.Neg()
+	}
+	return difference.Cmp(tolerance) <= 0
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func patchCells(t *testing.T, s *server, cells map[string]string) {
+	t.Helper()
+	form := make(url.Values)
+	for id, expression := range cells {
+		form.Set("cell-"+id, expression)
+	}
+	req := httptest.NewRequest(http.MethodPatch, "/table", strings.NewReader(form.Encode()))
+	req.Header.Set("content-type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func newTestServer(columns, rows int) *server {
+	return &server{
+		workbook:  engine.NewWorkbook(columns, rows),
+		history:   history{depth: defaultHistoryDepth},
+		templates: template.Must(template.New("index.html.template").Parse(indexHTMLTemplate)),
+	}
+}
@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"cmp"
+	"iter"
+	"slices"
+)
+
+// Cell returns the cell at column and row. When no cell is stored there, it
+// returns an empty cell that is not added to the table.
+func (table *Table) Cell(column, row int) *Cell {
+	if cell, ok := table.cells[CellIdentifier{column: column, row: row}]; ok {
+		return cell
+	}
+	return &Cell{
+		Row:    row,
+		Column: column,
+	}
+}
+
+// CellPointer returns the cell stored at column and row adding an empty cell
+// to the table if none is stored there yet.
+func (table *Table) CellPointer(column, row int) *Cell {
+	id := CellIdentifier{column: column, row: row}
+	if cell, ok := table.cells[id]; ok {
+		return cell
+	}
+	return table.SetCell(Cell{Row: row, Column: column})
+}
+
+// SetCell stores a copy of cell at its column and row replacing any cell
+// previously stored there.
+func (table *Table) SetCell(cell Cell) *Cell {
+	if table.cells == nil {
+		table.cells = make(map[CellIdentifier]*Cell)
+	}
+	stored := &cell
+	table.cells[CellIdentifier{column: cell.Column, row: cell.Row}] = stored
+	return stored
+}
+
+// DeleteCell removes the cell stored at column and row.
+func (table *Table) DeleteCell(column, row int) {
+	delete(table.cells, CellIdentifier{column: column, row: row})
+}
+
+// CellCount returns the number of stored cells.
+func (table *Table) CellCount() int {
+	return len(table.cells)
+}
+
+// Cells iterates over the stored cells ordered by row and then by column.
+func (table *Table) Cells() iter.Seq[*Cell] {
+	return func(yield func(*Cell) bool) {
+		ids := make([]CellIdentifier, 0, len(table.cells))
+		for id := range table.cells {
+			ids = append(ids, id)
+		}
+		slices.SortFunc(ids, func(a, b CellIdentifier) int {
+			return cmp.Or(cmp.Compare(a.row, b.row), cmp.Compare(a.column, b.column))
+		})
+		for _, id := range ids {
+			cell, ok := table.cells[id]
+			if !ok {
+				continue
+			}
+			if !yield(cell) {
+				return
+			}
+		}
+	}
+}
+
+// rangeCells returns the stored cells inside the range ordered by row and
+// then by column. A range covering more cells than the table stores is not
+// walked cell by cell, the stored cells are filtered instead. Each cell
+// visited uses a step of the budget, a nil budget is not limited.
+func (table *Table) rangeCells(r RangeNode, b *budget) ([]*Cell, error) {
+	minColumn, minRow, maxColumn, maxRow := r.Bounds()
+	minColumn, minRow = max(minColumn, 0), max(minRow, 0)
+	maxColumn, maxRow = min(maxColumn, table.ColumnCount-1), min(maxRow, table.RowCount-1)
+	if minColumn > maxColumn || minRow > maxRow {
+		return nil, nil
+	}
+	var cells []*Cell
+	if (maxColumn-minColumn+1)*(maxRow-minRow+1) <= len(table.cells) {
+		for row := minRow; row <= maxRow; row++ {
+			for column := minColumn; column <= maxColumn; column++ {
+				if err := b.step(); err != nil {
+					return nil, err
+				}
+				if cell, ok := table.cells[CellIdentifier{column: column, row: row}]; ok {
+					cells = append(cells, cell)
+				}
+			}
+		}
+		return cells, nil
+	}
+	for id, cell := range table.cells {
+		if err := b.step(); err != nil {
+			return nil, err
+		}
+		if r.contains(id.column, id.row) {
+			cells = append(cells, cell)
+		}
+	}
+	slices.SortFunc(cells, func(a, b *Cell) int {
+		return cmp.Or(cmp.Compare(a.Row, b.Row), cmp.Compare(a.Column, b.Column))
+	})
+	return cells, nil
+}
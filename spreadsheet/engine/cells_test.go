@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestTable_Cells_order(t *testing.T) {
+	table := NewTable(5, 5)
+	for _, id := range []CellIdentifier{{column: 2, row: 1}, {column: 0, row: 3}, {column: 1, row: 1}, {column: 4, row: 0}} {
+		table.SetCell(Cell{Column: id.column, Row: id.row})
+	}
+	table.DeleteCell(0, 3)
+
+	var got []string
+	for cell := range table.Cells() {
+		got = append(got, cell.Label())
+	}
+	exp := []string{"E0", "B1", "C1"}
+	if len(got) != len(exp) {
+		t.Fatalf("expected %v got %v", exp, got)
+	}
+	for i := range exp {
+		if got[i] != exp[i] {
+			t.Errorf("expected %v got %v", exp, got)
+		}
+	}
+	if table.CellCount() != 3 {
+		t.Errorf("expected 3 cells got %d", table.CellCount())
+	}
+}
+
+func TestTable_Cell_missing(t *testing.T) {
+	table := NewTable(5, 5)
+	cell := table.Cell(3, 4)
+	if cell.Column != 3 || cell.Row != 4 {
+		t.Errorf("unexpected cell position %s", cell.Label())
+	}
+	if table.CellCount() != 0 {
+		t.Errorf("expected Cell not to store a cell")
+	}
+	if table.CellPointer(3, 4) != table.CellPointer(3, 4) {
+		t.Errorf("expected CellPointer to return the stored cell")
+	}
+}
+
+func TestTable_MarshalJSON(t *testing.T) {
+	in, err := os.ReadFile("testdata/table.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var table Table
+	if err := json.Unmarshal(in, &table); err != nil {
+		t.Fatal(err)
+	}
+	if got := table.Cell(1, 0).Value; got != NewNumber(50) {
+		t.Errorf("expected B0 to be 50 got %s", got)
+	}
+
+	out, err := json.Marshal(&table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const exp = `{"columns":10,"rows":20,"cells":[{"id":"A0","ex":"100"},{"id":"B0","ex":"A0 / A1"},{"id":"A1","ex":"2"}]}`
+	if string(out) != exp {
+		t.Errorf("unexpected JSON\nexp: %s\ngot: %s", exp, out)
+	}
+}
+
+func TestTable_JSON_decimals(t *testing.T) {
+	const in = `{"columns":2,"rows":2,"cells":[{"id":"A0","ex":"1.25"},{"id":"A1","ex":"A0 * 2"}]}`
+	var table Table
+	if err := json.Unmarshal([]byte(in), &table); err != nil {
+		t.Fatal(err)
+	}
+	if got := table.Cell(0, 1).String(); got != "2.5" {
+		t.Errorf("unexpected A1 value %s", got)
+	}
+	out, err := json.Marshal(&table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != in {
+		t.Errorf("unexpected JSON\nexp: %s\ngot: %s", in, out)
+	}
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// csvFormulaPrefix marks a CSV field as a formula. Fields without it are
+// read as numbers when they parse as one and as text otherwise.
+const csvFormulaPrefix = "="
+
+// WriteCSV writes one record per table row. With formulas set, each field
+// holds the saved expression of the cell, otherwise the calculated value.
+func (table *Table) WriteCSV(w io.Writer, formulas bool) error {
+	writer := csv.NewWriter(w)
+	record := make([]string, table.ColumnCount)
+	for row := range table.RowCount {
+		for column := range record {
+			cell := table.Cell(column, row)
+			if formulas {
+				record[column] = csvFormula(cell.SavedExpression)
+			} else {
+				record[column] = cell.String()
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvFormula encodes an expression so ReadTableCSV reads back the same
+// expression. Numbers and text that do not look like a formula or a number
+// are written as they are, everything else gets the formula prefix.
+func csvFormula(expression ExpressionNode) string {
+	switch node := expression.(type) {
+	case nil:
+		return ""
+	case NumberNode:
+		return node.Value.String()
+	case TextNode:
+		text := node.Token.Value
+		if _, err := ParseNumber(text); err != nil && text != "" && !strings.HasPrefix(text, csvFormulaPrefix) {
+			return text
+		}
+	}
+	return csvFormulaPrefix + expression.String()
+}
+
+// ReadTableCSV reads a table from CSV. The table has a row for each record
+// and as many columns as the longest record. Fields starting with "=" are
+// parsed as formulas.
+func ReadTableCSV(r io.Reader) (Table, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return Table{}, err
+	}
+	if len(records) == 0 {
+		return Table{}, errors.New("csv file has no rows")
+	}
+	table := NewTable(0, len(records))
+	for _, record := range records {
+		table.ColumnCount = max(table.ColumnCount, len(record))
+	}
+	for row, record := range records {
+		for column, field := range record {
+			if field == "" {
+				continue
+			}
+			exp, refs, err := csvExpression(field, table.ColumnCount-1, table.RowCount-1)
+			if err != nil {
+				return Table{}, fmt.Errorf("row %d column %s: %w", row, ColumnLabel(column), err)
+			}
+			table.SetCell(Cell{
+				Column:          column,
+				Row:             row,
+				SavedExpression: exp,
+				Expression:      exp,
+				References:      refs,
+				SavedReferences: refs,
+			})
+		}
+	}
+	table.calculateValues()
+	return table, nil
+}
+
+func csvExpression(field string, maxColumn, maxRow int) (ExpressionNode, []CellIdentifier, error) {
+	if formula, ok := strings.CutPrefix(field, csvFormulaPrefix); ok {
+		return newExpression(normalizeExpression(formula), maxColumn, maxRow)
+	}
+	if n, err := ParseNumber(field); err == nil {
+		return NumberNode{Token: Token{Type: TokenNumber, Value: n.String()}, Value: n}, nil, nil
+	}
+	return TextNode{Token: Token{Type: TokenString, Value: field}}, nil, nil
+}
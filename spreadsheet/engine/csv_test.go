@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTable_writeCSV(t *testing.T) {
+	workbook := NewWorkbook(3, 2)
+	setCells(t, workbook, map[string]string{"A0": `"Price"`, "B0": "12.5", "C0": "B0 * 2", "A1": `"=not a formula"`, "B1": `"42"`, "C1": "B0 / 0"})
+
+	for _, tt := range []struct {
+		Name     string
+		Formulas bool
+		Result   string
+	}{
+		{Name: "values", Result: "Price,12.5,25\n=not a formula,42,#DIV/0!\n"},
+		{Name: "formulas", Formulas: true, Result: "Price,12.5,=B0 * 2\n\"=\"\"=not a formula\"\"\",\"=\"\"42\"\"\",=B0 / 0\n"},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := workbook.sheets[0].WriteCSV(&buf, tt.Formulas); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.Result {
+				t.Errorf("unexpected CSV\nexp: %q\ngot: %q", tt.Result, got)
+			}
+		})
+	}
+}
+
+func Test_readTableCSV(t *testing.T) {
+	table, err := ReadTableCSV(strings.NewReader("Item,Cost\nrent,=sum(b2:b3)\n,1000\n,250.75,\"=\"\"a, b\"\"\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.ColumnCount != 3 || table.RowCount != 4 {
+		t.Errorf("expected a 3 by 4 table got %d by %d", table.ColumnCount, table.RowCount)
+	}
+	for _, tt := range []struct {
+		ID     string
+		Result Value
+	}{
+		{ID: "A0", Result: Text("Item")},
+		{ID: "A1", Result: Text("rent")},
+		{ID: "B1", Result: Number{coefficient: 125075, scale: 2}},
+		{ID: "B2", Result: NewNumber(1000)},
+		{ID: "C3", Result: Text("a, b")},
+	} {
+		column, row, _ := ParseCellID(tt.ID, table.ColumnCount-1, table.RowCount-1)
+		if got := table.Cell(column, row).Value; got != tt.Result {
+			t.Errorf("expected %s to be %s got %s", tt.ID, tt.Result, got)
+		}
+	}
+	if got := table.Cell(1, 1).SavedExpression.String(); got != "SUM(B2:B3)" {
+		t.Errorf("expected the formula to be normalized got %s", got)
+	}
+}
+
+func Test_readTableCSV_errors(t *testing.T) {
+	for _, tt := range []struct {
+		Name, Input, Error string
+	}{
+		{Name: "empty", Input: "", Error: "no rows"},
+		{Name: "formula", Input: "1,2\n3,=A0 +\n", Error: "row 1 column B: "},
+		{Name: "reference outside of the table", Input: "1,=C0\n", Error: "row 0 column B: "},
+		{Name: "quotes", Input: "1,\"2\n", Error: "line 1"},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			_, err := ReadTableCSV(strings.NewReader(tt.Input))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.Error) {
+				t.Errorf("expected error to contain %q got %q", tt.Error, err)
+			}
+		})
+	}
+}
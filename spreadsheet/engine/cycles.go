@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// cycle is called when the cell identified by id is referenced while it is
+// being evaluated. Every cell on the path from id back to itself is part of
+// the cycle, each of them gets an error showing the cycle starting at the
+// cell.
+func (state walkState) cycle(id CellIdentifier) error {
+	path := *state.path
+	cycle := path[slices.Index(path, id):]
+	for i, cid := range cycle {
+		if _, ok := state.cycles[cid]; ok {
+			continue
+		}
+		rotated := append(slices.Clone(cycle[i:]), cycle[:i]...)
+		state.cycles[cid] = newErrorValue(ErrorCycle, "circular reference %s", cyclePath(cid.sheet, rotated))
+	}
+	return state.cycles[id]
+}
+
+// cyclePath formats a cycle like A3 → B1 → C4 → A3. Cells of sheets other
+// than sheet are written with the sheet name.
+func cyclePath(sheet string, cycle []CellIdentifier) string {
+	labels := make([]string, 0, len(cycle)+1)
+	for _, id := range append(cycle, cycle[0]) {
+		labels = append(labels, globalLabel(sheet, id))
+	}
+	return strings.Join(labels, " → ")
+}
+
+const (
+	defaultMaxIterations = 100
+	maxIterationsLimit   = 10_000
+)
+
+var defaultMaxChange = Number{coefficient: 1, scale: 3}
+
+// Iteration configures iterative calculation. When it is enabled cells in a
+// circular reference are calculated repeatedly, each time using the values
+// of the previous iteration, instead of showing #CYCLE!. Calculation stops
+// after MaxIterations or once no number changed by more than MaxChange.
+type Iteration struct {
+	Enabled       bool
+	MaxIterations int
+	MaxChange     Number
+}
+
+// Iteration returns the iterative calculation settings of the workbook.
+func (workbook *Workbook) Iteration() Iteration {
+	if workbook.iteration == nil {
+		return Iteration{MaxIterations: defaultMaxIterations, MaxChange: defaultMaxChange}
+	}
+	return *workbook.iteration
+}
+
+// SetIteration changes the iterative calculation settings and calculates
+// the workbook again.
+func (workbook *Workbook) SetIteration(iteration Iteration) error {
+	if err := iteration.check(); err != nil {
+		return err
+	}
+	workbook.iteration = &iteration
+	workbook.calculateValues()
+	return nil
+}
+
+func (iteration Iteration) check() error {
+	if iteration.MaxIterations < 1 || iteration.MaxIterations > maxIterationsLimit {
+		return fmt.Errorf("the maximum number of iterations must be between 1 and %d got %d", maxIterationsLimit, iteration.MaxIterations)
+	}
+	if iteration.MaxChange.Sign() < 0 {
+		return fmt.Errorf("the maximum change must not be negative got %s", iteration.MaxChange)
+	}
+	return nil
+}
+
+// iterate calculates the cells of circular references and the cells that
+// depend on them. The cells start out empty and are calculated in the
+// order of their sheet, row and column.
+func (workbook *Workbook) iterate(cyclic []CellIdentifier, b *budget) {
+	iteration := workbook.Iteration()
+	slices.SortFunc(cyclic, CompareCellIdentifiers)
+	dirty := make(map[CellIdentifier]bool, len(cyclic))
+	cells := make([]*Cell, 0, len(cyclic))
+	sheets := make([]*Table, 0, len(cyclic))
+	for _, id := range cyclic {
+		sheet := workbook.sheetByKey(id.sheet)
+		if sheet == nil {
+			continue
+		}
+		cell := sheet.CellPointer(id.column, id.row)
+		cell.Value = nil
+		dirty[id] = true
+		cells = append(cells, cell)
+		sheets = append(sheets, sheet)
+	}
+	previous := make([]Value, len(cells))
+	for range iteration.MaxIterations {
+		state := newWalkState(len(cells))
+		state.dirty, state.budget, state.iterative = dirty, b, true
+		for i, cell := range cells {
+			previous[i] = cell.Value
+		}
+		for i, cell := range cells {
+			_ = cell.evaluate(sheets[i], state)
+		}
+		settled := true
+		for i, cell := range cells {
+			if !withinChange(previous[i], cell.Value, iteration.MaxChange) {
+				settled = false
+				break
+			}
+		}
+		if settled {
+			return
+		}
+	}
+}
+
+// withinChange reports whether a value changed by no more than maxChange
+// between two iterations.
+func withinChange(previous, next Value, maxChange Number) bool {
+	a, aIsNumber := previous.(Number)
+	b, bIsNumber := next.(Number)
+	if !aIsNumber || !bIsNumber {
+		return EqualValues(previous, next)
+	}
+	difference, err := b.Sub(a)
+	if err != nil {
+		return false
+	}
+	if difference.Sign() < 0 {
+		difference = difference.Neg()
+	}
+	return difference.Cmp(maxChange) <= 0
+}
+
+type EncodedIteration struct {
+	MaxIterations int    `json:"max_iterations"`
+	MaxChange     string `json:"max_change"`
+}
+
+func (workbook *Workbook) encodeIteration() *EncodedIteration {
+	iteration := workbook.Iteration()
+	if !iteration.Enabled {
+		return nil
+	}
+	return &EncodedIteration{MaxIterations: iteration.MaxIterations, MaxChange: iteration.MaxChange.String()}
+}
+
+func (workbook *Workbook) decodeIteration(encoded *EncodedIteration) error {
+	workbook.iteration = nil
+	if encoded == nil {
+		return nil
+	}
+	maxChange, err := ParseNumber(encoded.MaxChange)
+	if err != nil {
+		return fmt.Errorf("iteration: %w", err)
+	}
+	iteration := Iteration{Enabled: true, MaxIterations: encoded.MaxIterations, MaxChange: maxChange}
+	if err := iteration.check(); err != nil {
+		return fmt.Errorf("iteration: %w", err)
+	}
+	workbook.iteration = &iteration
+	return nil
+}
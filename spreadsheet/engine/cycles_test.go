@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestWorkbook_cyclePath(t *testing.T) {
+	workbook := NewWorkbook(5, 5)
+	if _, _, err := workbook.AddSheet("Other", 2, 2); err != nil {
+		t.Fatal(err)
+	}
+	setCells(t, workbook, map[string]string{"A3": "B1", "B1": "C4", "C4": "A3 + 1", "D0": "A3 * 2", "E0": "OTHER!A0"})
+	sheet, other := workbook.sheets[0], workbook.sheets[1]
+	workbook.EditCells(other, []CellEdit{{Column: 0, Row: 0, Input: "SHEET1!E0"}})
+	for _, tt := range []struct {
+		Table   *Table
+		ID      string
+		Message string
+	}{
+		{Table: sheet, ID: "A3", Message: "circular reference A3 → B1 → C4 → A3"},
+		{Table: sheet, ID: "B1", Message: "circular reference B1 → C4 → A3 → B1"},
+		{Table: sheet, ID: "C4", Message: "circular reference C4 → A3 → B1 → C4"},
+		{Table: sheet, ID: "E0", Message: "circular reference E0 → OTHER!A0 → E0"},
+		{Table: other, ID: "A0", Message: "circular reference A0 → SHEET1!E0 → A0"},
+	} {
+		column, row, err := ParseCellID(tt.ID, 4, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cell := tt.Table.Cell(column, row)
+		if cell.ErrorKind() != string(ErrorCycle) || cell.ErrorMessage() != tt.Message {
+			t.Errorf("expected %s to show %q got %s %q", tt.ID, tt.Message, cell.ErrorKind(), cell.ErrorMessage())
+		}
+	}
+	if got := sheet.Cell(3, 0).ErrorKind(); got != string(ErrorCycle) {
+		t.Errorf("expected the cell depending on the cycle to show the error got %q", got)
+	}
+
+	// breaking the cycle calculates every cell of it again
+	setCells(t, workbook, map[string]string{"C4": "1"})
+	if got := sheet.Cell(0, 3).String(); got != "1" {
+		t.Errorf("expected A3 to be 1 got %s", got)
+	}
+	if got := sheet.Cell(3, 0).String(); got != "2" {
+		t.Errorf("expected D0 to be 2 got %s", got)
+	}
+}
+
+func Test_withinChange(t *testing.T) {
+	r := RangeValue{Rows: 1, Columns: 1, cells: []rangeCell{{value: NewNumber(1)}}}
+	maxChange, _ := ParseNumber("0.1")
+	for _, tt := range []struct {
+		Previous, Next Value
+		Within         bool
+	}{
+		{Previous: NewNumber(1), Next: NewNumber(1), Within: true},
+		{Previous: Number{coefficient: 105, scale: 2}, Next: NewNumber(1), Within: true},
+		{Previous: NewNumber(2), Next: NewNumber(1)},
+		{Previous: Text("a"), Next: Text("a"), Within: true},
+		{Previous: r, Next: r, Within: true},
+		{Previous: r, Next: NewNumber(1)},
+	} {
+		if got := withinChange(tt.Previous, tt.Next, maxChange); got != tt.Within {
+			t.Errorf("expected the change from %v to %v within %s to be %t", tt.Previous, tt.Next, maxChange, tt.Within)
+		}
+	}
+}
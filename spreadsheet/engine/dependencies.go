@@ -0,0 +1,237 @@
+package engine
+
+import (
+	"maps"
+)
+
+// The dependency graph of a workbook uses the global identifiers of cells so
+// edges can cross from one sheet to another. A range is a single edge, the
+// cells it covers are only looked up while the graph is walked.
+
+// link replaces the dependency edges of the cell identified by id with
+// edges to each of the references and ranges.
+func (workbook *Workbook) link(id CellIdentifier, references []CellIdentifier, ranges []RangeNode) {
+	if workbook.dependencies == nil {
+		workbook.dependencies = make(map[CellIdentifier]map[CellIdentifier]struct{})
+		workbook.dependents = make(map[CellIdentifier]map[CellIdentifier]struct{})
+	}
+	for ref := range workbook.dependencies[id] {
+		delete(workbook.dependents[ref], id)
+		if len(workbook.dependents[ref]) == 0 {
+			delete(workbook.dependents, ref)
+		}
+	}
+	delete(workbook.dependencies, id)
+	workbook.rangeDependents.set(id, ranges)
+	if len(references) == 0 {
+		return
+	}
+	edges := make(map[CellIdentifier]struct{}, len(references))
+	for _, ref := range references {
+		edges[ref] = struct{}{}
+		if workbook.dependents[ref] == nil {
+			workbook.dependents[ref] = make(map[CellIdentifier]struct{})
+		}
+		workbook.dependents[ref][id] = struct{}{}
+	}
+	workbook.dependencies[id] = edges
+}
+
+// dependentsOf returns the cells referencing the cell, directly or with a
+// range covering it.
+func (workbook *Workbook) dependentsOf(id CellIdentifier) map[CellIdentifier]struct{} {
+	dependents := workbook.dependents[id]
+	copied := false
+	for dependent := range workbook.rangeDependents.covering(id) {
+		if !copied {
+			dependents, copied = maps.Clone(dependents), true
+			if dependents == nil {
+				dependents = make(map[CellIdentifier]struct{})
+			}
+		}
+		dependents[dependent] = struct{}{}
+	}
+	return dependents
+}
+
+// precedentsOf returns the cells the cell references, directly or with a
+// range. Only the stored cells of a range are returned.
+func (workbook *Workbook) precedentsOf(id CellIdentifier) map[CellIdentifier]struct{} {
+	precedents := workbook.dependencies[id]
+	copied := false
+	for _, r := range workbook.rangeDependents.ranges[id] {
+		sheet := workbook.sheetByKey(r.From.Sheet)
+		if sheet == nil {
+			continue
+		}
+		cells, _ := sheet.rangeCells(r, nil)
+		for _, cell := range cells {
+			if !copied {
+				precedents, copied = maps.Clone(precedents), true
+				if precedents == nil {
+					precedents = make(map[CellIdentifier]struct{})
+				}
+			}
+			precedents[sheet.CellID(cell.Column, cell.Row)] = struct{}{}
+		}
+	}
+	return precedents
+}
+
+// AffectedCells returns the changed cells and every cell that transitively
+// depends on them.
+func (workbook *Workbook) AffectedCells(changed []CellIdentifier) map[CellIdentifier]bool {
+	affected := make(map[CellIdentifier]bool, len(changed))
+	queue := make([]CellIdentifier, 0, len(changed))
+	for _, id := range changed {
+		if !affected[id] {
+			affected[id] = true
+			queue = append(queue, id)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for dependent := range workbook.dependentsOf(id) {
+			if affected[dependent] {
+				continue
+			}
+			affected[dependent] = true
+			queue = append(queue, dependent)
+		}
+	}
+	return affected
+}
+
+// evaluationOrder sorts the affected cells so each cell comes after the
+// affected cells it references. Cells that are part of a cycle, or depend on
+// one, can not be ordered, they are returned separately.
+func (workbook *Workbook) evaluationOrder(affected map[CellIdentifier]bool) (order, cyclic []CellIdentifier) {
+	inDegree := make(map[CellIdentifier]int, len(affected))
+	for id := range affected {
+		for dependent := range workbook.dependentsOf(id) {
+			if affected[dependent] && dependent != id {
+				inDegree[dependent]++
+			}
+		}
+	}
+	order = make([]CellIdentifier, 0, len(affected))
+	for id := range affected {
+		if inDegree[id] == 0 {
+			order = append(order, id)
+		}
+	}
+	for i := 0; i < len(order); i++ {
+		for dependent := range workbook.dependentsOf(order[i]) {
+			if !affected[dependent] || dependent == order[i] {
+				continue
+			}
+			inDegree[dependent]--
+			if inDegree[dependent] == 0 {
+				order = append(order, dependent)
+			}
+		}
+	}
+	for id := range affected {
+		if inDegree[id] > 0 {
+			cyclic = append(cyclic, id)
+		}
+	}
+	return order, cyclic
+}
+
+// recalculate updates the dependency graph for the changed cells and then
+// evaluates only the changed cells and their transitive dependents. The
+// changed cells are identified by their global identifiers. Cells that fail
+// to evaluate hold an ErrorValue; the other cells are still calculated and
+// saved. It returns the cells it evaluated.
+func (workbook *Workbook) recalculate(changed []CellIdentifier) map[CellIdentifier]bool {
+	for _, id := range changed {
+		if sheet := workbook.sheetByKey(id.sheet); sheet != nil {
+			cell := sheet.Cell(id.column, id.row)
+			references := append(sheet.globalReferences(cell.References), workbook.nameReferences(cell.Expression)...)
+			workbook.link(id, references, workbook.ranges(sheet, cell.Expression))
+		}
+	}
+	affected := workbook.AffectedCells(changed)
+	state := newWalkState(len(affected))
+	state.dirty = affected
+	order, cyclic := workbook.evaluationOrder(affected)
+	if workbook.Iteration().Enabled {
+		workbook.evaluateCells(order, state)
+		workbook.iterate(cyclic, state.budget)
+	} else {
+		// evaluating the cells of a cycle reports the circular reference
+		workbook.evaluateCells(append(order, cyclic...), state)
+	}
+	for id := range affected {
+		if sheet := workbook.sheetByKey(id.sheet); sheet != nil {
+			sheet.saveCellChange(id.column, id.row)
+		}
+	}
+	return affected
+}
+
+func (workbook *Workbook) evaluateCells(ids []CellIdentifier, state walkState) {
+	for _, id := range ids {
+		if sheet := workbook.sheetByKey(id.sheet); sheet != nil {
+			_ = sheet.Cell(id.column, id.row).evaluate(sheet, state)
+		}
+	}
+}
+
+// calculateValues rebuilds the dependency graph and evaluates every cell of
+// every sheet.
+func (workbook *Workbook) calculateValues() {
+	workbook.dependencies, workbook.dependents, workbook.rangeDependents = nil, nil, rangeIndex{}
+	var ids []CellIdentifier
+	for _, sheet := range workbook.sheets {
+		for id := range sheet.cells {
+			ids = append(ids, sheet.CellID(id.column, id.row))
+		}
+	}
+	workbook.recalculate(ids)
+}
+
+// globalReferences returns the references of a cell of the table with the
+// sheet set to the key of the referenced sheet.
+func (table *Table) globalReferences(references []CellIdentifier) []CellIdentifier {
+	result := make([]CellIdentifier, len(references))
+	for i, ref := range references {
+		if ref.sheet == "" {
+			ref.sheet = table.Key()
+		}
+		result[i] = ref
+	}
+	return result
+}
+
+// ranges returns the ranges used in the expression of a cell of the table,
+// directly or through names, with the sheet set to the key of the
+// referenced sheet. The ranges are cut to the size of their sheet; the
+// part outside evaluates to #REF! and resizing a sheet rebuilds the
+// dependency graph.
+func (workbook *Workbook) ranges(table *Table, expression ExpressionNode) []RangeNode {
+	var ranges []RangeNode
+	mapReferences(expression, func(node ExpressionNode) ExpressionNode {
+		reference := node
+		if name, ok := node.(NameNode); ok {
+			reference = workbook.names[name.Token.Value]
+		}
+		if r, ok := reference.(RangeNode); ok {
+			if r.From.Sheet == "" {
+				r.From.Sheet, r.To.Sheet = table.Key(), table.Key()
+			}
+			if sheet := workbook.sheetByKey(r.From.Sheet); sheet != nil {
+				minColumn, minRow, maxColumn, maxRow := r.Bounds()
+				r.From.Column, r.From.Row = minColumn, minRow
+				r.To.Column, r.To.Row = min(maxColumn, sheet.ColumnCount-1), min(maxRow, sheet.RowCount-1)
+				if r.To.Column >= minColumn && r.To.Row >= minRow {
+					ranges = append(ranges, r)
+				}
+			}
+		}
+		return node
+	})
+	return ranges
+}
@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"testing"
+)
+
+// setCells sets the expressions of cells of the first sheet by their
+// identifiers, like B3.
+func setCells(t *testing.T, workbook *Workbook, cells map[string]string) {
+	t.Helper()
+	sheet := workbook.Sheets()[0]
+	var edits []CellEdit
+	for id, expression := range cells {
+		column, row, err := ParseCellID(id, sheet.ColumnCount-1, sheet.RowCount-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		edits = append(edits, CellEdit{Column: column, Row: row, Input: expression})
+	}
+	workbook.EditCells(sheet, edits)
+}
+
+func TestWorkbook_recalculate_matchesFullRecalculation(t *testing.T) {
+	workbook := NewWorkbook(5, 5)
+
+	for _, edit := range []map[string]string{
+		{"A0": "1", "A1": "2", "A2": "3"},
+		{"B0": "A0 + A1", "B1": "B0 * A2", "B2": "SUM(A0:B1)"},
+		{"C0": "B2 - B0", "D0": "C0 + 1"},
+		{"A0": "10"},
+		{"A1": "A0 * 2"},
+		{"B0": ""},
+		{"E4": "MAX(A0:D0)"},
+		{"A2": "E4 + 1"}, // recursive via B1, B2, C0, D0, E4
+		{"A2": "7"},
+	} {
+		setCells(t, workbook, edit)
+
+		full := NewTable(5, 5)
+		for cell := range workbook.sheets[0].Cells() {
+			full.SetCell(Cell{
+				Row:        cell.Row,
+				Column:     cell.Column,
+				Expression: cell.SavedExpression,
+				References: cell.SavedReferences,
+			})
+		}
+		full.calculateValues()
+		for cell := range full.Cells() {
+			got := workbook.sheets[0].Cell(cell.Column, cell.Row)
+			// error messages depend on where evaluation started so only the
+			// displayed values are compared
+			if got.String() != cell.String() {
+				t.Errorf("after %v: expected %s to be %s but got %s", edit, cell.Label(), cell.Value, got.Value)
+			}
+		}
+	}
+}
+
+func TestWorkbook_recalculate_onlyDependents(t *testing.T) {
+	workbook := NewWorkbook(3, 3)
+	setCells(t, workbook, map[string]string{"A0": "1", "A1": "A0 + 1", "B0": "5", "B1": "B0 + 1"})
+
+	// a stale value in an unrelated cell must not be touched
+	workbook.sheets[0].Cell(1, 1).Value = NewNumber(100)
+
+	setCells(t, workbook, map[string]string{"A0": "2"})
+
+	if got := workbook.sheets[0].Cell(0, 1).Value; got != NewNumber(3) {
+		t.Errorf("expected dependent A1 to be recalculated to 3 but got %s", got)
+	}
+	if got := workbook.sheets[0].Cell(1, 1).Value; got != NewNumber(100) {
+		t.Errorf("expected unrelated B1 to be left alone but got %s", got)
+	}
+}
+
+func TestWorkbook_recalculate_cycleErrorValues(t *testing.T) {
+	workbook := NewWorkbook(3, 3)
+	setCells(t, workbook, map[string]string{"A0": "1", "A1": "A0 + 1", "B0": "7"})
+	setCells(t, workbook, map[string]string{"A0": "A1", "B0": "8"})
+
+	for _, id := range []string{"A0", "A1"} {
+		column, row, _ := ParseCellID(id, 2, 2)
+		if got := workbook.sheets[0].Cell(column, row).ErrorKind(); got != string(ErrorCycle) {
+			t.Errorf("expected %s to be %s got %q", id, ErrorCycle, got)
+		}
+	}
+	if got := workbook.sheets[0].Cell(0, 0).SavedExpression.String(); got != "A1" {
+		t.Errorf("expected A0 to be saved got %s", got)
+	}
+	if got := workbook.sheets[0].Cell(1, 0).Value; got != NewNumber(8) {
+		t.Errorf("expected the healthy edit to B0 to be saved got %s", got)
+	}
+
+	setCells(t, workbook, map[string]string{"A0": "3"})
+	if got := workbook.sheets[0].Cell(0, 1).Value; got != NewNumber(4) {
+		t.Errorf("expected A1 to be 4 but got %s", got)
+	}
+}
+
+func TestWorkbook_recalculate_largeRange(t *testing.T) {
+	workbook := NewWorkbook(100, 100000)
+	setCells(t, workbook, map[string]string{"A0": "SUM(B0:K99999)", "B5": "3", "K99999": "2"})
+	sheet := workbook.sheets[0]
+	if got := sheet.Cell(0, 0).String(); got != "5" {
+		t.Errorf("expected the sum to be 5 got %s", got)
+	}
+	if got := len(workbook.dependents); got != 0 {
+		t.Errorf("expected the range to not be expanded into %d edges", got)
+	}
+
+	setCells(t, workbook, map[string]string{"B5": "4"})
+	if got := sheet.Cell(0, 0).String(); got != "6" {
+		t.Errorf("expected the sum to be calculated again got %s", got)
+	}
+	graph := workbook.Graph(sheet, 0, 0, Precedents)
+	if len(graph.Cells) != 2 {
+		t.Errorf("expected the precedents to be the stored cells of the range got %+v", graph.Cells)
+	}
+}
+
+func TestWorkbook_dependentsOf_rangeBlocks(t *testing.T) {
+	workbook := NewWorkbook(40, 40)
+	setCells(t, workbook, map[string]string{"A0": "SUM(B1:AH33)"})
+	sheet := workbook.sheets[0]
+	id := sheet.CellID(0, 0)
+	for _, tt := range []struct {
+		Column, Row int
+		Covered     bool
+	}{
+		{Column: 1, Row: 1, Covered: true},
+		{Column: 33, Row: 33, Covered: true},
+		{Column: 17, Row: 20, Covered: true},
+		{Column: 0, Row: 20},
+		{Column: 34, Row: 1},
+		{Column: 5, Row: 34},
+	} {
+		if _, ok := workbook.dependentsOf(sheet.CellID(tt.Column, tt.Row))[id]; ok != tt.Covered {
+			t.Errorf("expected %s%d covered to be %t", ColumnLabel(tt.Column), tt.Row, tt.Covered)
+		}
+	}
+
+	setCells(t, workbook, map[string]string{"A0": "1"})
+	if len(workbook.rangeDependents.blocks) != 0 {
+		t.Errorf("expected the range to be removed from every block")
+	}
+}
@@ -0,0 +1,17 @@
+// Package engine calculates spreadsheets. It parses the expressions of
+// cells, like SUM(A0:A9) * 2, tracks the references between cells and
+// calculates the cells that depend on an edit.
+//
+// A Table is a grid of cells addressed by zero based column and row; the
+// cell in the first column and row is A0. Use SetExpression to change a
+// cell and Value to read its calculated value or error. A Workbook holds
+// named sheets that can reference each other, like OTHER!B3, and defined
+// names. Its editing methods return a Change that Apply reverts so edits
+// can be undone.
+//
+// Tables and workbooks are encoded as JSON with encoding/json, tables also
+// as CSV and XLSX. Functions implemented in Go are added with
+// RegisterFunction.
+//
+// Tables and workbooks are not safe for concurrent use.
+package engine
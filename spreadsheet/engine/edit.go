@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CellEdit is the new input of the cell at Column and Row, an expression
+// like 1 + A0 or an empty string to clear the cell.
+type CellEdit struct {
+	Column, Row int
+	Input       string
+}
+
+// EditCells sets the input of cells of the sheet and calculates them and the
+// cells that depend on them. A cell whose input does not parse keeps its
+// previous expression and has Error set. It returns the change, which
+// Apply reverts, and the cells that were calculated.
+func (workbook *Workbook) EditCells(sheet *Table, edits []CellEdit) (*Change, map[CellIdentifier]bool) {
+	var (
+		changed  []CellIdentifier
+		previous = make(map[CellIdentifier]cellState)
+	)
+	for _, edit := range edits {
+		if editCell(sheet, edit.Column, edit.Row, edit.Input, previous) {
+			changed = append(changed, sheet.CellID(edit.Column, edit.Row))
+		}
+	}
+	return workbook.commitCells(sheet, changed, previous)
+}
+
+// editCell sets the input of the cell at column and row and parses it. It
+// adds the saved state of the cell to previous. It returns false when the
+// input does not parse, the error is then set on the cell.
+func editCell(sheet *Table, column, row int, input string, previous map[CellIdentifier]cellState) bool {
+	cell := sheet.CellPointer(column, row)
+	cell.Error = ""
+	cell.input = normalizeExpression(input)
+	previous[sheet.CellID(column, row)] = savedCellState(cell)
+
+	var (
+		expression ExpressionNode
+		refs       []CellIdentifier
+	)
+	if cell.input != "" {
+		var err error
+		expression, refs, err = newExpression(cell.input, sheet.ColumnCount-1, sheet.RowCount-1)
+		if err != nil {
+			cell.Error = err.Error()
+			return false
+		}
+		cell.input = expression.String()
+	}
+	cell.Expression = expression
+	cell.References = refs
+	return true
+}
+
+// commitCells calculates the changed cells of the sheet and the cells that
+// depend on them. Previous holds the saved state of each changed cell from
+// before the edit.
+func (workbook *Workbook) commitCells(sheet *Table, changed []CellIdentifier, previous map[CellIdentifier]cellState) (*Change, map[CellIdentifier]bool) {
+	affected := workbook.recalculate(changed)
+	c := new(Change)
+	for _, id := range changed {
+		c.addCell(sheet, id.column, id.row, previous[id], savedCellState(sheet.Cell(id.column, id.row)))
+	}
+	return c, affected
+}
+
+// SetExpression sets the input of the cell at column and row and calculates
+// the table, or the workbook when the table is one of its sheets. It
+// returns the parse error when the input does not parse.
+func (table *Table) SetExpression(column, row int, input string) error {
+	if column < 0 || column >= table.ColumnCount || row < 0 || row >= table.RowCount {
+		return fmt.Errorf("cell %s is out of range", cellReferenceText(column, row, false, false))
+	}
+	if table.workbook != nil {
+		table.workbook.EditCells(table, []CellEdit{{Column: column, Row: row, Input: input}})
+	} else if editCell(table, column, row, input, make(map[CellIdentifier]cellState)) {
+		table.calculateValues()
+	}
+	if cell := table.Cell(column, row); cell.Error != "" {
+		return errors.New(cell.Error)
+	}
+	return nil
+}
+
+// Value returns the calculated value of the cell at column and row. It is
+// nil for an empty cell. When the cell evaluated to an error, like #DIV/0!,
+// the error is an ErrorValue.
+func (table *Table) Value(column, row int) (Value, error) {
+	cell := table.Cell(column, row)
+	if err, ok := cell.Value.(ErrorValue); ok {
+		return nil, err
+	}
+	return cell.Value, nil
+}
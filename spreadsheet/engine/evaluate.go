@@ -0,0 +1,301 @@
+package engine
+
+type walkState struct {
+	temporal  map[CellIdentifier]bool
+	permanent map[CellIdentifier]bool
+
+	// dirty, when set, limits evaluation to the cells it contains. The
+	// values of all other cells are treated as up to date.
+	dirty map[CellIdentifier]bool
+
+	budget *budget
+
+	// path holds the cells being evaluated, the innermost last, so a
+	// recursive reference can report the whole cycle. cycles holds the
+	// error of each cell found to be part of a cycle.
+	path   *[]CellIdentifier
+	cycles map[CellIdentifier]ErrorValue
+
+	// iterative is set while circular references are calculated
+	// iteratively. A recursive reference then gets the value the cell had
+	// after the previous iteration.
+	iterative bool
+}
+
+func newWalkState(n int) walkState {
+	return walkState{
+		temporal:  make(map[CellIdentifier]bool, n),
+		permanent: make(map[CellIdentifier]bool, n),
+		budget:    newBudget(limits.Steps),
+		path:      new([]CellIdentifier),
+		cycles:    make(map[CellIdentifier]ErrorValue),
+	}
+}
+
+// evaluate calculates the value of the cell. When the expression fails to
+// evaluate, the cell's value is set to an ErrorValue which is also returned
+// so the error propagates to the cells that reference this one.
+func (cell *Cell) evaluate(table *Table, state walkState) error {
+	cid := table.CellID(cell.Column, cell.Row)
+	if state.permanent[cid] || (state.dirty != nil && !state.dirty[cid]) {
+		return cell.valueError()
+	}
+	if state.temporal[cid] {
+		if state.iterative {
+			return cell.valueError()
+		}
+		return state.cycle(cid)
+	}
+	if cell.Expression == nil {
+		state.permanent[cid] = true
+		cell.Value = nil
+		return nil
+	}
+	state.temporal[cid] = true
+	*state.path = append(*state.path, cid)
+	result, err := evaluate(table, cell, state, cell.Expression)
+	*state.path = (*state.path)[:len(*state.path)-1]
+	state.permanent[cid] = true
+	if cycle, ok := state.cycles[cid]; ok {
+		result = cycle
+	} else if err != nil {
+		result = toErrorValue(err)
+	} else if result == nil {
+		// a cell that only references an empty cell shows zero
+		result = Number{}
+	} else if r, ok := result.(RangeValue); ok {
+		result = newErrorValue(ErrorValueType, "range %s can only be used as a function argument", r)
+	}
+	cell.Value = result
+	return cell.valueError()
+}
+
+func (cell *Cell) valueError() error {
+	if errorValue, ok := cell.Value.(ErrorValue); ok {
+		return errorValue
+	}
+	return nil
+}
+
+const (
+	RowIdent       = "ROW"
+	ColumnIdent    = "COLUMN"
+	MaxRowIdent    = "MAX_ROW"
+	MaxColumnIdent = "MAX_COLUMN"
+	MinRowIdent    = "MIN_ROW"
+	MinColumnIdent = "MIN_COLUMN"
+
+	TrueIdent  = "TRUE"
+	FalseIdent = "FALSE"
+)
+
+func evaluate(table *Table, cell *Cell, state walkState, expressionNode ExpressionNode) (Value, error) {
+	if err := state.budget.step(); err != nil {
+		return nil, err
+	}
+	switch node := expressionNode.(type) {
+	case IdentifierNode:
+		sheet, err := table.sheet(node.Sheet)
+		if err != nil {
+			return nil, err
+		}
+		if node.Column < 0 || node.Row < 0 || node.Column >= sheet.ColumnCount || node.Row >= sheet.RowCount {
+			return nil, newErrorValue(ErrorReference, "%s is outside of sheet %s", node, sheet.Name)
+		}
+		cell := sheet.Cell(node.Column, node.Row)
+		err = cell.evaluate(sheet, state)
+		return cell.Value, err
+	case NumberNode:
+		return node.Value, nil
+	case ErrorNode:
+		return nil, newErrorValue(ErrorKind(node.Token.Value), "the expression holds %s", node.Token.Value)
+	case NameNode:
+		reference, err := table.name(node.Token.Value)
+		if err != nil {
+			return nil, err
+		}
+		return evaluate(table, cell, state, reference)
+	case TextNode:
+		return Text(node.Token.Value), nil
+	case BooleanNode:
+		return Boolean(node.Value), nil
+	case ParenNode:
+		return evaluate(table, cell, state, node.Node)
+	case RangeNode:
+		return nil, newErrorValue(ErrorValueType, "range %s can only be used as a function argument", node)
+	case FunctionNode:
+		fn, ok := functions[node.Name.Value]
+		if !ok {
+			return nil, newErrorValue(ErrorName, "unknown function %s", node.Name.Value)
+		}
+		if fn.lazy != nil {
+			// the arguments evaluate like expressions so a range is an
+			// error rather than the result of the function
+			return fn.lazy(node.Arguments, func(argument ExpressionNode) (Value, error) {
+				return evaluate(table, cell, state, argument)
+			})
+		}
+		arguments, err := evaluateArguments(table, cell, state, node.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		return fn.call(arguments)
+	case VariableNode:
+		switch node.Identifier.Value {
+		case RowIdent:
+			return NewNumber(cell.Row), nil
+		case ColumnIdent:
+			return NewNumber(cell.Column), nil
+		case MaxRowIdent:
+			return NewNumber(table.RowCount - 1), nil
+		case MaxColumnIdent:
+			return NewNumber(table.ColumnCount - 1), nil
+		case MinRowIdent, MinColumnIdent:
+			return NewNumber(0), nil
+		default:
+			return nil, newErrorValue(ErrorName, "unknown variable %s", node.Identifier.Value)
+		}
+	case UnaryExpressionNode:
+		value, err := evaluate(table, cell, state, node.Expression)
+		if err != nil {
+			return nil, err
+		}
+		number, err := toNumber(value)
+		if err != nil {
+			return nil, err
+		}
+		if node.Op.Type == TokenSubtract {
+			return number.Neg(), nil
+		}
+		return number, nil
+	case FactorialNode:
+		value, err := evaluate(table, cell, state, node.Expression)
+		if err != nil {
+			return nil, err
+		}
+		number, err := toNumber(value)
+		if err != nil {
+			return nil, err
+		}
+		n, ok := number.Int()
+		if !ok || n < 0 {
+			return nil, newErrorValue(ErrorNumber, "n! requires n to be a non-negative integer")
+		}
+		if n > 20 {
+			return nil, newErrorValue(ErrorNumber, "n! where n > 20 is too large")
+		}
+		result := 1
+		for i := n; i >= 2; i-- {
+			result *= i
+		}
+		return NewNumber(result), nil
+	case BinaryExpressionNode:
+		left, err := evaluate(table, cell, state, node.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := evaluate(table, cell, state, node.Right)
+		if err != nil {
+			return nil, err
+		}
+		switch node.Op.Type {
+		case TokenEqual, TokenNotEqual, TokenLess, TokenLessOrEqual, TokenGreater, TokenGreaterOrEqual:
+			return compareValues(node.Op, left, right)
+		}
+		if node.Op.Type == TokenConcatenate {
+			leftText, err := toText(left)
+			if err != nil {
+				return nil, err
+			}
+			rightText, err := toText(right)
+			if err != nil {
+				return nil, err
+			}
+			return leftText + rightText, nil
+		}
+		leftResult, err := toNumber(left)
+		if err != nil {
+			return nil, err
+		}
+		rightResult, err := toNumber(right)
+		if err != nil {
+			return nil, err
+		}
+		switch node.Op.Type {
+		case TokenAdd:
+			return leftResult.Add(rightResult)
+		case TokenSubtract:
+			return leftResult.Sub(rightResult)
+		case TokenMultiply:
+			return leftResult.Mul(rightResult)
+		case TokenExponent:
+			exponent, ok := rightResult.Int()
+			if !ok {
+				return nil, newErrorValue(ErrorNumber, "exponent %s must be an integer", rightResult)
+			}
+			if exponent < 0 {
+				return nil, newErrorValue(ErrorNumber, "exponent %s must not be negative", rightResult)
+			}
+			return leftResult.Pow(exponent)
+		case TokenDivide:
+			return leftResult.Div(rightResult)
+		default:
+			return nil, newErrorValue(ErrorName, "unknown binary operator %s", node.Op.Value)
+		}
+	default:
+		return nil, newErrorValue(ErrorValueType, "unknown expression node")
+	}
+}
+
+// evaluateArguments evaluates function arguments. Range arguments evaluate
+// to a RangeValue holding the values of the stored cells they cover.
+func evaluateArguments(table *Table, cell *Cell, state walkState, arguments []ExpressionNode) ([]Value, error) {
+	values := make([]Value, 0, len(arguments))
+	for _, argument := range arguments {
+		value, err := evaluateArgument(table, cell, state, argument)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func evaluateArgument(table *Table, cell *Cell, state walkState, argument ExpressionNode) (Value, error) {
+	if name, ok := argument.(NameNode); ok {
+		reference, err := table.name(name.Token.Value)
+		if err != nil {
+			return nil, err
+		}
+		argument = reference
+	}
+	r, ok := argument.(RangeNode)
+	if !ok {
+		return evaluate(table, cell, state, argument)
+	}
+	sheet, err := table.sheet(r.From.Sheet)
+	if err != nil {
+		return nil, err
+	}
+	minColumn, minRow, maxColumn, maxRow := r.Bounds()
+	if minColumn < 0 || minRow < 0 || maxColumn >= sheet.ColumnCount || maxRow >= sheet.RowCount {
+		return nil, newErrorValue(ErrorReference, "%s is outside of sheet %s", r, sheet.Name)
+	}
+	cells, err := sheet.rangeCells(r, state.budget)
+	if err != nil {
+		return nil, err
+	}
+	rangeValue := RangeValue{Node: r, Rows: maxRow - minRow + 1, Columns: maxColumn - minColumn + 1}
+	for _, c := range cells {
+		if c.Expression == nil {
+			continue
+		}
+		if err := c.evaluate(sheet, state); err != nil {
+			return nil, err
+		}
+		if c.Value != nil {
+			rangeValue.cells = append(rangeValue.cells, rangeCell{row: c.Row - minRow, column: c.Column - minColumn, value: c.Value})
+		}
+	}
+	return rangeValue, nil
+}
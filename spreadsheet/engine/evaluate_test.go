@@ -0,0 +1,271 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_parse(t *testing.T) {
+	for _, tt := range []struct {
+		Name       string
+		Expression string
+		Result     int
+	}{
+		{
+			Name:       "just 1",
+			Expression: "1",
+			Result:     1,
+		},
+		{
+			Name:       "add",
+			Expression: "1 + 2",
+			Result:     3,
+		},
+		{
+			Name:       "subtract",
+			Expression: "1 - 2",
+			Result:     -1,
+		},
+		{
+			Name:       "multiply",
+			Expression: "2 * 3",
+			Result:     6,
+		},
+		{
+			Name:       "divide",
+			Expression: "6 / 2",
+			Result:     3,
+		},
+		{
+			Name:       "no space",
+			Expression: "8/2",
+			Result:     4,
+		},
+		{
+			Name:       "space around",
+			Expression: " 8/2 ",
+			Result:     4,
+		},
+		{
+			Name:       "cell in cells slice",
+			Expression: "A1",
+			Result:     100,
+		},
+		{
+			Name:       "cell not in cells slice",
+			Expression: "J9",
+			Result:     0,
+		},
+		{
+			Name:       "multiple multiply expressions",
+			Expression: "1 * 2 * 3",
+			Result:     6,
+		},
+		{
+			Name:       "precedence order",
+			Expression: "1 * 2 + 3",
+			Result:     5,
+		},
+		{
+			Name:       "non precedence order",
+			Expression: "1 + 2 * 3",
+			Result:     7,
+		},
+		{
+			Name:       "non precedence order on both sides",
+			Expression: "1 + 2 * 3 + 4",
+			Result:     11,
+		},
+		{
+			Name:       "number in parens",
+			Expression: "(1)",
+			Result:     1,
+		},
+		{
+			Name:       "two sets of parens in middle",
+			Expression: "(1 + 2) * (3 + 4)",
+			Result:     21,
+		},
+		{
+			Name:       "one set of parens with binary op with higher president",
+			Expression: "2 * (3 + 4)",
+			Result:     14,
+		},
+		{
+			Name:       "division has higher president over subtraction",
+			Expression: "100 - 6 / 3",
+			Result:     98,
+		},
+		{
+			Name:       "factorial has higher president over subtraction",
+			Expression: "1 - 3!",
+			Result:     -5,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			tokens, err := tokenize(tt.Expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			table := NewTable(10, 10)
+			table.SetCell(Cell{Column: 0, Row: 1, Value: NewNumber(100), Expression: NumberNode{Value: NewNumber(100)}})
+			exp, _, _, err := parse(tokens, 0, 10-1, 10-1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			state := newWalkState(table.CellCount())
+
+			cell := Cell{Column: 0, Row: 0, Expression: exp}
+
+			if err := cell.evaluate(&table, state); err != nil {
+				t.Fatal(err)
+			}
+
+			if value := cell.Value; value != NewNumber(tt.Result) {
+				t.Errorf("expected %d but got %s", tt.Result, value)
+			}
+		})
+	}
+}
+
+func Test_columnLabels(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		label := ColumnLabel(i)
+		t.Run(label, func(t *testing.T) {
+			result := columnNumber(label)
+			if result != i {
+				t.Errorf("expected %d got %d", i, result)
+			}
+		})
+	}
+}
+
+func TestParseCellID_longColumn(t *testing.T) {
+	for _, id := range []string{"ZZZZZZZZZZZZZZ1", "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAA1"} {
+		if column, _, err := ParseCellID(id, 4, 4); err == nil {
+			t.Errorf("expected %s to be out of range got column %d", id, column)
+		}
+	}
+	table := NewTable(5, 5)
+	if err := table.SetExpression(0, 0, "SUM(B0:ZZZZZZZZZZZZZZ1)"); err == nil {
+		t.Errorf("expected a range to a column outside of the table to fail")
+	}
+
+	// the size of another sheet is only checked when the range is evaluated
+	workbook := NewWorkbook(2, 2)
+	if _, _, err := workbook.AddSheet("S2", 2, 2); err != nil {
+		t.Fatal(err)
+	}
+	setCells(t, workbook, map[string]string{"A0": "SUM(S2!B0:ZZZZZZZZZZZZZZ1)"})
+	if got := workbook.sheets[0].Cell(0, 0).ErrorKind(); got != string(ErrorReference) {
+		t.Errorf("expected the range to be %s got %q", ErrorReference, got)
+	}
+}
+
+func Test_functions(t *testing.T) {
+	for _, tt := range []struct {
+		Name       string
+		Expression string
+		Result     int
+	}{
+		{Name: "sum of a column", Expression: "SUM(A0:A2)", Result: 6},
+		{Name: "sum of a reversed range", Expression: "SUM(A2:A0)", Result: 6},
+		{Name: "sum of a block", Expression: "SUM(A0:B2)", Result: 16},
+		{Name: "sum with scalar arguments", Expression: "SUM(A0:A2, 4, B0)", Result: 20},
+		{Name: "average", Expression: "AVG(A0:A2)", Result: 2},
+		{Name: "average skips empty cells", Expression: "AVG(A0:A5)", Result: 2},
+		{Name: "min", Expression: "MIN(A0:B2)", Result: 1},
+		{Name: "max", Expression: "MAX(A0:B2)", Result: 10},
+		{Name: "count skips empty cells", Expression: "COUNT(A0:C9)", Result: 4},
+		{Name: "nested function", Expression: "SUM(MAX(A0:A2), MIN(A0:A2))", Result: 4},
+		{Name: "function in binary expression", Expression: "1 + SUM(A0:A1) * 2", Result: 7},
+		{Name: "function with expression argument", Expression: "SUM((1 + 2) * 3, 1)", Result: 10},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			table := NewTable(10, 10)
+			for _, c := range []struct {
+				ID         string
+				Expression string
+			}{
+				{ID: "A0", Expression: "1"},
+				{ID: "A1", Expression: "2"},
+				{ID: "A2", Expression: "3"},
+				{ID: "B0", Expression: "10"},
+			} {
+				column, row, err := ParseCellID(c.ID, 9, 9)
+				if err != nil {
+					t.Fatal(err)
+				}
+				exp, refs, err := newExpression(c.Expression, 9, 9)
+				if err != nil {
+					t.Fatal(err)
+				}
+				table.SetCell(Cell{Column: column, Row: row, Expression: exp, References: refs})
+			}
+			exp, _, err := newExpression(tt.Expression, 9, 9)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cell := Cell{Column: 5, Row: 5}
+			value, err := evaluate(&table, &cell, newWalkState(table.CellCount()), exp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != NewNumber(tt.Result) {
+				t.Errorf("expected %d but got %s", tt.Result, value)
+			}
+		})
+	}
+}
+
+func Test_rangeReferences(t *testing.T) {
+	workbook := NewWorkbook(10, 10)
+	setCells(t, workbook, map[string]string{"C0": "SUM(A0:B1)"})
+	id := workbook.sheets[0].CellID(2, 0)
+	if got := len(workbook.rangeDependents.ranges[id]); got != 1 {
+		t.Fatalf("expected the range to be a single edge got %d", got)
+	}
+	for _, column := range []int{0, 1} {
+		for _, row := range []int{0, 1} {
+			if _, ok := workbook.dependentsOf(workbook.sheets[0].CellID(column, row))[id]; !ok {
+				t.Errorf("expected %s%d to have C0 as a dependent", ColumnLabel(column), row)
+			}
+		}
+	}
+	if _, ok := workbook.dependentsOf(workbook.sheets[0].CellID(0, 2))[id]; ok {
+		t.Errorf("expected A2 outside of the range to not have C0 as a dependent")
+	}
+}
+
+func Test_rangeCycle(t *testing.T) {
+	var table Table
+	err := json.Unmarshal([]byte(`{"columns": 3, "rows": 3, "cells": [{"id": "A0", "ex": "1"}, {"id": "A1", "ex": "SUM(A0:A2)"}]}`), &table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := table.Cell(0, 1).ErrorKind(); got != string(ErrorCycle) {
+		t.Errorf("expected A1 to be %s got %q", ErrorCycle, got)
+	}
+	if got := table.Cell(0, 0).Value; got != NewNumber(1) {
+		t.Errorf("expected A0 to be 1 got %s", got)
+	}
+}
+
+func Test_functionErrors(t *testing.T) {
+	for _, expression := range []string{
+		"NOPE(A0)",
+		"SUM()",
+		"SUM(A0:A1",
+		"SUM(A0,,A1)",
+		"A0:",
+		"1, 2",
+	} {
+		t.Run(expression, func(t *testing.T) {
+			_, _, err := newExpression(expression, 9, 9)
+			if err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
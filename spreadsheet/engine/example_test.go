@@ -0,0 +1,139 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func Example() {
+	table := engine.NewTable(3, 3)
+	for _, cell := range []struct {
+		Column, Row int
+		Expression  string
+	}{
+		{Column: 0, Row: 0, Expression: "20"},
+		{Column: 0, Row: 1, Expression: "22"},
+		{Column: 0, Row: 2, Expression: "SUM(A0:A1)"},
+	} {
+		if err := table.SetExpression(cell.Column, cell.Row, cell.Expression); err != nil {
+			log.Fatal(err)
+		}
+	}
+	value, err := table.Value(0, 2)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(value)
+	// Output: 42
+}
+
+func ExampleTable_Value() {
+	table := engine.NewTable(2, 1)
+	if err := table.SetExpression(0, 0, "1 / B0"); err != nil {
+		log.Fatal(err)
+	}
+	_, err := table.Value(0, 0)
+	fmt.Println(err)
+
+	err = table.SetExpression(1, 0, "1 +")
+	fmt.Println(err != nil)
+	// Output:
+	// #DIV/0! could not divide by zero
+	// true
+}
+
+func ExampleTable_Cells() {
+	table := engine.NewTable(3, 3)
+	for _, expression := range []string{`"apples"`, "3", "B0 * 2"} {
+		column := table.CellCount()
+		if err := table.SetExpression(column, 0, expression); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for cell := range table.Cells() {
+		fmt.Printf("%s = %s is %s\n", cell.Label(), cell.ExpressionText(), cell.String())
+	}
+	// Output:
+	// A0 = "apples" is apples
+	// B0 = 3 is 3
+	// C0 = B0 * 2 is 6
+}
+
+func ExampleWorkbook_EditCells() {
+	workbook := engine.NewWorkbook(2, 2)
+	prices, _, err := workbook.AddSheet("Prices", 2, 2)
+	if err != nil {
+		log.Fatal(err)
+	}
+	prices.SetExpression(0, 0, "2.5")
+	orders := workbook.Sheets()[0]
+	change, _ := workbook.EditCells(orders, []engine.CellEdit{
+		{Column: 0, Row: 0, Input: "4"},
+		{Column: 1, Row: 0, Input: "A0 * PRICES!A0"},
+	})
+	fmt.Println(orders.Cell(1, 0))
+
+	workbook.Apply(change, true)
+	fmt.Printf("%q\n", orders.Cell(1, 0))
+	// Output:
+	// 10
+	// ""
+}
+
+func ExampleParseWorkbook() {
+	workbook := engine.NewWorkbook(2, 2)
+	sheet := workbook.Sheets()[0]
+	sheet.SetExpression(0, 0, "6")
+	sheet.SetExpression(0, 1, "A0 * 7")
+
+	buf, err := json.Marshal(workbook)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(buf))
+
+	loaded, err := engine.ParseWorkbook(buf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(loaded.Sheets()[0].Cell(0, 1))
+	// Output:
+	// {"sheets":[{"name":"Sheet1","columns":2,"rows":2,"cells":[{"id":"A0","ex":"6"},{"id":"A1","ex":"A0 * 7"}]}]}
+	// 42
+}
+
+func ExampleTable_WriteCSV() {
+	table := engine.NewTable(2, 2)
+	table.SetExpression(0, 0, "1")
+	table.SetExpression(1, 0, "A0 + 1")
+	table.SetExpression(0, 1, `"total"`)
+
+	if err := table.WriteCSV(os.Stdout, true); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// 1,=A0 + 1
+	// total,
+}
+
+func ExampleRegisterFunction() {
+	err := engine.RegisterFunction("DOUBLE", 1, 1, func(arguments []engine.Value) (engine.Value, error) {
+		n, ok := arguments[0].(engine.Number)
+		if !ok {
+			return nil, engine.TypeError{Expected: "a number", Got: arguments[0]}
+		}
+		return n.Mul(engine.NewNumber(2))
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	table := engine.NewTable(1, 1)
+	table.SetExpression(0, 0, "DOUBLE(21)")
+	fmt.Println(table.Cell(0, 0))
+	// Output: 42
+}
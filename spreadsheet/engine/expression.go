@@ -0,0 +1,317 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type Token struct {
+	Type  TokenType
+	Value string
+	Index int
+}
+
+type TokenType int
+
+const (
+	TokenNumber TokenType = iota
+	TokenEqual
+	TokenNotEqual
+	TokenLess
+	TokenLessOrEqual
+	TokenGreater
+	TokenGreaterOrEqual
+	TokenConcatenate
+	TokenAdd
+	TokenSubtract
+	TokenMultiply
+	TokenDivide
+	TokenExponent
+	TokenExclamation
+	TokenLeftParenthesis
+	TokenRightParenthesis
+	TokenIdentifier
+	TokenComma
+	TokenColon
+	TokenString
+	TokenError
+)
+
+func tokenize(input string) ([]Token, error) {
+	var tokens []Token
+
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+
+		if isDigit(c) {
+			start := i
+			dotCount := 0
+			for i < len(input) && (isDigit(input[i]) || (dotCount == 0 && input[i] == '.')) {
+				if input[i] == '.' {
+					dotCount++
+				}
+				i++
+			}
+			tokens = append(tokens, Token{Index: start, Type: TokenNumber, Value: input[start:i]})
+			i--
+		} else if c == '"' {
+			start := i
+			var sb strings.Builder
+			for i++; ; i++ {
+				if i >= len(input) {
+					return nil, parseErrorf(start, "text is missing a closing quote")
+				}
+				if input[i] == '"' {
+					if i+1 < len(input) && input[i+1] == '"' {
+						sb.WriteByte('"')
+						i++
+						continue
+					}
+					break
+				}
+				sb.WriteByte(input[i])
+			}
+			tokens = append(tokens, Token{Index: start, Type: TokenString, Value: sb.String()})
+		} else if kind, ok := errorKindPrefix(input[i:]); ok {
+			tokens = append(tokens, Token{Index: i, Type: TokenError, Value: kind})
+			i += len(kind) - 1
+		} else if c == '&' {
+			tokens = append(tokens, Token{Index: i, Type: TokenConcatenate, Value: "&"})
+		} else if c == '=' {
+			tokens = append(tokens, Token{Index: i, Type: TokenEqual, Value: "="})
+		} else if c == '<' {
+			if i+1 < len(input) && input[i+1] == '>' {
+				tokens = append(tokens, Token{Index: i, Type: TokenNotEqual, Value: "<>"})
+				i++
+			} else if i+1 < len(input) && input[i+1] == '=' {
+				tokens = append(tokens, Token{Index: i, Type: TokenLessOrEqual, Value: "<="})
+				i++
+			} else {
+				tokens = append(tokens, Token{Index: i, Type: TokenLess, Value: "<"})
+			}
+		} else if c == '>' {
+			if i+1 < len(input) && input[i+1] == '=' {
+				tokens = append(tokens, Token{Index: i, Type: TokenGreaterOrEqual, Value: ">="})
+				i++
+			} else {
+				tokens = append(tokens, Token{Index: i, Type: TokenGreater, Value: ">"})
+			}
+		} else if c == '+' {
+			tokens = append(tokens, Token{Index: i, Type: TokenAdd, Value: "+"})
+		} else if c == '!' {
+			tokens = append(tokens, Token{Index: i, Type: TokenExclamation, Value: "!"})
+		} else if c == '-' {
+			tokens = append(tokens, Token{Index: i, Type: TokenSubtract, Value: "-"})
+		} else if c == '*' {
+			tokens = append(tokens, Token{Index: i, Type: TokenMultiply, Value: "*"})
+		} else if c == '/' {
+			tokens = append(tokens, Token{Index: i, Type: TokenDivide, Value: "/"})
+		} else if c == '^' {
+			tokens = append(tokens, Token{Index: i, Type: TokenExponent, Value: "^"})
+		} else if c == '(' {
+			tokens = append(tokens, Token{Index: i, Type: TokenLeftParenthesis, Value: "("})
+		} else if c == ')' {
+			tokens = append(tokens, Token{Index: i, Type: TokenRightParenthesis, Value: ")"})
+		} else if c == ',' {
+			tokens = append(tokens, Token{Index: i, Type: TokenComma, Value: ","})
+		} else if c == ':' {
+			tokens = append(tokens, Token{Index: i, Type: TokenColon, Value: ":"})
+		} else if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			continue
+		} else if isLetter(c) || c == '_' || (c == '$' && i+1 < len(input) && isLetter(input[i+1])) {
+			start := i
+			for i < len(input) && (input[i] == '_' || input[i] == '$' || isLetter(input[i]) || isDigit(input[i])) {
+				i++
+			}
+			tokens = append(tokens, Token{Index: start, Type: TokenIdentifier, Value: input[start:i]})
+			i--
+		} else {
+			r, _ := utf8.DecodeRuneInString(input[i:])
+			return nil, parseErrorf(i, "unexpected character %q", r)
+		}
+	}
+
+	return tokens, nil
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z')
+}
+
+type ExpressionNode interface {
+	fmt.Stringer
+}
+
+func newExpression(in string, maxColumn, maxRow int) (ExpressionNode, []CellIdentifier, error) {
+	expressionText := normalizeExpression(in)
+	if length := utf8.RuneCountInString(expressionText); length > limits.FormulaLength {
+		return nil, nil, fmt.Errorf("the formula is %d characters long it must be at most %d", length, limits.FormulaLength)
+	}
+	tokens, err := tokenize(expressionText)
+	if err != nil {
+		return nil, nil, err
+	}
+	expression, references, _, err := parse(tokens, 0, maxColumn, maxRow)
+	if err != nil {
+		return nil, nil, err
+	}
+	return expression, references, nil
+}
+
+type IdentifierNode struct {
+	Token Token
+
+	// Sheet names the sheet of a cross sheet reference like SHEET2!B3. It
+	// is empty for references to the sheet of the cell.
+	Sheet string
+
+	Row, Column int
+
+	// AbsoluteColumn and AbsoluteRow are set by a $ before the column or the
+	// row, like $A$1. They stay the same when a formula is filled into
+	// other cells.
+	AbsoluteColumn, AbsoluteRow bool
+}
+
+func (node IdentifierNode) String() string {
+	if node.Sheet != "" {
+		return node.Sheet + "!" + node.Token.Value
+	}
+	return node.Token.Value
+}
+
+// ErrorNode is an error value written in an expression, like #REF! for a
+// reference that was moved outside of the sheet.
+type ErrorNode struct {
+	Token Token
+}
+
+func (node ErrorNode) String() string {
+	return node.Token.Value
+}
+
+type NumberNode struct {
+	Token Token
+	Value Number
+}
+
+func (node NumberNode) String() string {
+	return node.Token.Value
+}
+
+type TextNode struct {
+	Token Token
+}
+
+func (node TextNode) String() string {
+	return quoteText(node.Token.Value)
+}
+
+type BooleanNode struct {
+	Token Token
+	Value bool
+}
+
+func (node BooleanNode) String() string {
+	return node.Token.Value
+}
+
+type UnaryExpressionNode struct {
+	Op         Token
+	Expression ExpressionNode
+}
+
+func (node UnaryExpressionNode) String() string {
+	return node.Op.Value + node.Expression.String()
+}
+
+type BinaryExpressionNode struct {
+	Op          Token
+	Left, Right ExpressionNode
+}
+
+func (node BinaryExpressionNode) String() string {
+	return fmt.Sprintf("%s %s %s", node.Left.String(), node.Op.Value, node.Right.String())
+}
+
+type VariableNode struct {
+	Identifier Token
+}
+
+func (node VariableNode) String() string {
+	return fmt.Sprintf("%s", node.Identifier.Value)
+}
+
+type FactorialNode struct {
+	Expression ExpressionNode
+}
+
+func (node FactorialNode) String() string {
+	return fmt.Sprintf("%s!", node.Expression)
+}
+
+type ParenNode struct {
+	Start, End Token
+	Node       ExpressionNode
+}
+
+func (node ParenNode) String() string {
+	return fmt.Sprintf("(%s)", node.Node)
+}
+
+type RangeNode struct {
+	From, To IdentifierNode
+}
+
+func (node RangeNode) String() string {
+	// the sheet of a range is only written once, before the first corner
+	return fmt.Sprintf("%s:%s", node.From, node.To.Token.Value)
+}
+
+// Bounds returns the top left and bottom right corners of the range
+// regardless of the order the corners were written in.
+func (node RangeNode) Bounds() (minColumn, minRow, maxColumn, maxRow int) {
+	return min(node.From.Column, node.To.Column), min(node.From.Row, node.To.Row),
+		max(node.From.Column, node.To.Column), max(node.From.Row, node.To.Row)
+}
+
+// contains reports whether the cell at column and row is inside the range.
+func (node RangeNode) contains(column, row int) bool {
+	minColumn, minRow, maxColumn, maxRow := node.Bounds()
+	return column >= minColumn && column <= maxColumn && row >= minRow && row <= maxRow
+}
+
+type FunctionNode struct {
+	Name      Token
+	Arguments []ExpressionNode
+}
+
+func (node FunctionNode) String() string {
+	arguments := make([]string, 0, len(node.Arguments))
+	for _, argument := range node.Arguments {
+		arguments = append(arguments, argument.String())
+	}
+	return fmt.Sprintf("%s(%s)", node.Name.Value, strings.Join(arguments, ", "))
+}
+
+// CellIdentifier identifies a cell. The sheet is empty for cells of the
+// same table. In the dependency graph of a workbook it is the key of the
+// cell's sheet.
+type CellIdentifier struct {
+	sheet       string
+	column, row int
+}
+
+// Sheet returns the key of the sheet of the cell, see Table.Key.
+func (id CellIdentifier) Sheet() string { return id.sheet }
+
+// Column returns the zero based column of the cell.
+func (id CellIdentifier) Column() int { return id.column }
+
+// Row returns the zero based row of the cell.
+func (id CellIdentifier) Row() int { return id.row }
@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ParseWorkbook reads a workbook from JSON. Syntax errors give the line and
+// column where the file stopped making sense.
+func ParseWorkbook(in []byte) (*Workbook, error) {
+	workbook := new(Workbook)
+	err := json.Unmarshal(in, workbook)
+	var (
+		syntaxError *json.SyntaxError
+		typeError   *json.UnmarshalTypeError
+	)
+	switch {
+	case err == nil:
+		return workbook, nil
+	case errors.As(err, &syntaxError):
+		line, column := jsonPosition(in, syntaxError.Offset)
+		return nil, fmt.Errorf("line %d column %d: %w", line, column, err)
+	case errors.As(err, &typeError):
+		line, column := jsonPosition(in, typeError.Offset)
+		return nil, fmt.Errorf("line %d column %d: %w", line, column, err)
+	default:
+		return nil, err
+	}
+}
+
+func jsonPosition(in []byte, offset int64) (line, column int) {
+	before := in[:min(int(offset), len(in))]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = len(before) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+func closeAndIgnoreError(c io.Closer) {
+	_ = c.Close()
+}
@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Fill copies the expression of the cell at column and row of the sheet
+// into every other cell of the target range, like dragging the fill handle
+// of a cell down or right. Relative references are shifted by the distance
+// to each cell. It returns the change and the cells that were calculated.
+// When a shifted expression fails to parse no cell is changed.
+func (workbook *Workbook) Fill(sheet *Table, column, row int, target RangeNode) (*Change, map[CellIdentifier]bool, error) {
+	maxColumn, maxRow := sheet.ColumnCount-1, sheet.RowCount-1
+	source := sheet.Cell(column, row).SavedExpression
+	type fill struct {
+		column, row int
+		expression  ExpressionNode
+		references  []CellIdentifier
+	}
+	var fills []fill
+	minColumn, minRow, maxTargetColumn, maxTargetRow := target.Bounds()
+	for r := minRow; r <= maxTargetRow; r++ {
+		for c := minColumn; c <= maxTargetColumn; c++ {
+			if c == column && r == row {
+				continue
+			}
+			f := fill{column: c, row: r}
+			if source != nil {
+				shifted := shiftReferences(source, c-column, r-row, maxColumn, maxRow)
+				var err error
+				f.expression, f.references, err = newExpression(shifted.String(), maxColumn, maxRow)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to fill %s: %w", cellReferenceText(c, r, false, false), err)
+				}
+			}
+			fills = append(fills, f)
+		}
+	}
+
+	var (
+		changed  = make([]CellIdentifier, 0, len(fills))
+		previous = make(map[CellIdentifier]cellState, len(fills))
+	)
+	for _, f := range fills {
+		cell := sheet.CellPointer(f.column, f.row)
+		previous[sheet.CellID(f.column, f.row)] = savedCellState(cell)
+		cell.Error = ""
+		cell.input = ""
+		if f.expression != nil {
+			cell.input = f.expression.String()
+		}
+		cell.Expression = f.expression
+		cell.References = f.references
+		changed = append(changed, sheet.CellID(f.column, f.row))
+	}
+	change, affected := workbook.commitCells(sheet, changed, previous)
+	return change, affected, nil
+}
+
+// shiftReferences returns a copy of the expression for a cell that is
+// columns and rows away from the cell it was written for. Relative parts of
+// the references move by the offset, absolute parts stay. A reference that
+// moves outside of the sheet becomes #REF!.
+func shiftReferences(node ExpressionNode, columns, rows, maxColumn, maxRow int) ExpressionNode {
+	return mapReferences(node, func(node ExpressionNode) ExpressionNode {
+		switch node := node.(type) {
+		case IdentifierNode:
+			shifted, ok := shiftIdentifier(node, columns, rows, maxColumn, maxRow)
+			if !ok {
+				return referenceErrorNode()
+			}
+			return shifted
+		case RangeNode:
+			from, fromOK := shiftIdentifier(node.From, columns, rows, maxColumn, maxRow)
+			to, toOK := shiftIdentifier(node.To, columns, rows, maxColumn, maxRow)
+			if !fromOK || !toOK {
+				return referenceErrorNode()
+			}
+			return RangeNode{From: from, To: to}
+		default:
+			return node
+		}
+	})
+}
+
+func shiftIdentifier(node IdentifierNode, columns, rows, maxColumn, maxRow int) (IdentifierNode, bool) {
+	if node.Sheet != "" {
+		// the size of other sheets is checked when the reference is evaluated
+		maxColumn, maxRow = math.MaxInt, math.MaxInt
+	}
+	if !node.AbsoluteColumn {
+		node.Column += columns
+	}
+	if !node.AbsoluteRow {
+		node.Row += rows
+	}
+	if node.Column < 0 || node.Row < 0 || node.Column > maxColumn || node.Row > maxRow {
+		return IdentifierNode{}, false
+	}
+	node.Token = Token{Type: TokenIdentifier, Value: cellReferenceText(node.Column, node.Row, node.AbsoluteColumn, node.AbsoluteRow)}
+	return node, true
+}
+
+func referenceErrorNode() ErrorNode {
+	return ErrorNode{Token: Token{Type: TokenError, Value: string(ErrorReference)}}
+}
+
+// ParseRange parses a range like A1:A9 or a single cell.
+func ParseRange(in string, maxColumn, maxRow int) (RangeNode, error) {
+	from, to, isRange := strings.Cut(strings.ToUpper(strings.TrimSpace(in)), ":")
+	if !isRange {
+		to = from
+	}
+	var node RangeNode
+	for _, corner := range []struct {
+		label string
+		node  *IdentifierNode
+	}{
+		{label: from, node: &node.From},
+		{label: to, node: &node.To},
+	} {
+		column, row, err := ParseCellID(corner.label, maxColumn, maxRow)
+		if err != nil {
+			return RangeNode{}, err
+		}
+		corner.node.Column, corner.node.Row = column, row
+	}
+	return node, nil
+}
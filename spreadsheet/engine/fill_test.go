@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"testing"
+)
+
+func Test_absoluteReferences(t *testing.T) {
+	for _, tt := range []struct {
+		Expression                  string
+		AbsoluteColumn, AbsoluteRow bool
+	}{
+		{Expression: "A1"},
+		{Expression: "$A$1", AbsoluteColumn: true, AbsoluteRow: true},
+		{Expression: "$A1", AbsoluteColumn: true},
+		{Expression: "A$1", AbsoluteRow: true},
+	} {
+		t.Run(tt.Expression, func(t *testing.T) {
+			exp, refs, err := newExpression(tt.Expression, 2, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			node, ok := exp.(IdentifierNode)
+			if !ok {
+				t.Fatalf("expected an identifier got %T", exp)
+			}
+			if node.Column != 0 || node.Row != 1 || node.AbsoluteColumn != tt.AbsoluteColumn || node.AbsoluteRow != tt.AbsoluteRow {
+				t.Errorf("unexpected reference %+v", node)
+			}
+			if got := exp.String(); got != tt.Expression {
+				t.Errorf("expected %s got %s", tt.Expression, got)
+			}
+			if len(refs) != 1 || refs[0] != (CellIdentifier{column: 0, row: 1}) {
+				t.Errorf("unexpected references %v", refs)
+			}
+		})
+	}
+
+	for _, expression := range []string{"A$$1", "$$A1", "A1$", "$A$9"} {
+		t.Run(expression, func(t *testing.T) {
+			if _, _, err := newExpression(expression, 2, 2); err == nil {
+				t.Errorf("expected %s to fail to parse", expression)
+			}
+		})
+	}
+}
+
+func Test_shiftReferences(t *testing.T) {
+	for _, tt := range []struct {
+		Expression    string
+		Columns, Rows int
+		Result        string
+	}{
+		{Expression: "A0 + 1", Rows: 2, Result: "A2 + 1"},
+		{Expression: "A0 * $B$0", Columns: 1, Rows: 1, Result: "B1 * $B$0"},
+		{Expression: "$A0 + A$0", Columns: 2, Rows: 3, Result: "$A3 + C$0"},
+		{Expression: "SUM(A0:B1)", Columns: 1, Result: "SUM(B0:C1)"},
+		{Expression: "SUM($A$0:A0)", Rows: 2, Result: "SUM($A$0:A2)"},
+		{Expression: "A1 + 1", Rows: -2, Result: "#REF! + 1"},
+		{Expression: "SUM(A0:D0)", Columns: 1, Result: "SUM(#REF!)"},
+		{Expression: "SHEET2!A0 + SHEET2!Z0", Columns: 1, Result: "SHEET2!B0 + SHEET2!AA0"},
+		{Expression: `"A0" & ROW`, Rows: 1, Result: `"A0" & ROW`},
+	} {
+		t.Run(tt.Expression, func(t *testing.T) {
+			exp, _, err := newExpression(tt.Expression, 3, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := shiftReferences(exp, tt.Columns, tt.Rows, 3, 3).String(); got != tt.Result {
+				t.Errorf("expected %s got %s", tt.Result, got)
+			}
+		})
+	}
+}
+
+func TestWorkbook_Fill_parseError(t *testing.T) {
+	workbook := NewWorkbook(2, 12)
+	setCells(t, workbook, map[string]string{"A0": "B8 + 1"})
+	sheet := workbook.sheets[0]
+	setLimits(t, Limits{FormulaLength: 6, NestingDepth: 64, Steps: 100})
+
+	// A1 gets B9 + 1 but B10 + 1 in A2 is too long
+	target, err := ParseRange("A0:A3", 1, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := workbook.Fill(sheet, 0, 0, target); err == nil {
+		t.Fatal("expected the fill to fail")
+	}
+	if got := sheet.CellCount(); got != 1 {
+		t.Errorf("expected no cell to be changed got %d cells", got)
+	}
+}
@@ -0,0 +1,250 @@
+package engine
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+type function struct {
+	// minArguments and maxArguments bound the number of arguments a
+	// function accepts, a maxArguments of zero means there is no upper
+	// bound.
+	minArguments,
+	maxArguments int
+
+	call func(arguments []Value) (Value, error)
+
+	// lazy, when set, is used instead of call. It receives the unevaluated
+	// arguments so a function like IF can skip the branch it does not take.
+	lazy func(arguments []ExpressionNode, evaluate func(ExpressionNode) (Value, error)) (Value, error)
+}
+
+var functions = map[string]function{
+	"SUM": {minArguments: 1, call: func(arguments []Value) (Value, error) {
+		numbers, err := numericArguments(arguments)
+		if err != nil {
+			return nil, err
+		}
+		return sum(numbers)
+	}},
+	"AVG": {minArguments: 1, call: func(arguments []Value) (Value, error) {
+		numbers, err := numericArguments(arguments)
+		if err != nil {
+			return nil, err
+		}
+		if len(numbers) == 0 {
+			return nil, newErrorValue(ErrorDivideByZero, "could not average an empty range")
+		}
+		total, err := sum(numbers)
+		if err != nil {
+			return nil, err
+		}
+		return total.Div(NewNumber(len(numbers)))
+	}},
+	"MIN": {minArguments: 1, call: func(arguments []Value) (Value, error) {
+		numbers, err := numericArguments(arguments)
+		if err != nil {
+			return nil, err
+		}
+		return extreme(numbers, -1), nil
+	}},
+	"MAX": {minArguments: 1, call: func(arguments []Value) (Value, error) {
+		numbers, err := numericArguments(arguments)
+		if err != nil {
+			return nil, err
+		}
+		return extreme(numbers, 1), nil
+	}},
+	"COUNT": {minArguments: 1, call: func(arguments []Value) (Value, error) {
+		numbers, err := numericArguments(arguments)
+		if err != nil {
+			return nil, err
+		}
+		return NewNumber(len(numbers)), nil
+	}},
+	"QUOTIENT": {minArguments: 2, maxArguments: 2, call: func(arguments []Value) (Value, error) {
+		numbers, err := numericArguments(arguments)
+		if err != nil {
+			return nil, err
+		}
+		return numbers[0].Quotient(numbers[1])
+	}},
+	"LEN": {minArguments: 1, maxArguments: 1, call: func(arguments []Value) (Value, error) {
+		text, err := toText(arguments[0])
+		if err != nil {
+			return nil, err
+		}
+		return NewNumber(utf8.RuneCountInString(string(text))), nil
+	}},
+	"UPPER": {minArguments: 1, maxArguments: 1, call: func(arguments []Value) (Value, error) {
+		text, err := toText(arguments[0])
+		if err != nil {
+			return nil, err
+		}
+		return Text(strings.ToUpper(string(text))), nil
+	}},
+	"LOWER": {minArguments: 1, maxArguments: 1, call: func(arguments []Value) (Value, error) {
+		text, err := toText(arguments[0])
+		if err != nil {
+			return nil, err
+		}
+		return Text(strings.ToLower(string(text))), nil
+	}},
+	"CONCAT": {minArguments: 1, call: func(arguments []Value) (Value, error) {
+		var sb strings.Builder
+		for _, value := range flatten(arguments) {
+			text, err := toText(value)
+			if err != nil {
+				return nil, err
+			}
+			sb.WriteString(string(text))
+		}
+		return Text(sb.String()), nil
+	}},
+	"IF": {minArguments: 2, maxArguments: 3, lazy: func(arguments []ExpressionNode, evaluate func(ExpressionNode) (Value, error)) (Value, error) {
+		value, err := evaluate(arguments[0])
+		if err != nil {
+			return nil, err
+		}
+		condition, err := toBoolean(value)
+		if err != nil {
+			return nil, err
+		}
+		if condition {
+			return evaluate(arguments[1])
+		}
+		if len(arguments) < 3 {
+			return Boolean(false), nil
+		}
+		return evaluate(arguments[2])
+	}},
+	"AND": {minArguments: 1, call: func(arguments []Value) (Value, error) {
+		booleans, err := booleanArguments(arguments)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range booleans {
+			if !b {
+				return Boolean(false), nil
+			}
+		}
+		return Boolean(true), nil
+	}},
+	"OR": {minArguments: 1, call: func(arguments []Value) (Value, error) {
+		booleans, err := booleanArguments(arguments)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range booleans {
+			if b {
+				return Boolean(true), nil
+			}
+		}
+		return Boolean(false), nil
+	}},
+	"INDEX":   {minArguments: 2, maxArguments: 3, call: index},
+	"MATCH":   {minArguments: 2, maxArguments: 3, call: match},
+	"VLOOKUP": {minArguments: 3, maxArguments: 4, call: vlookup},
+	"HLOOKUP": {minArguments: 3, maxArguments: 4, call: hlookup},
+	"NOT": {minArguments: 1, maxArguments: 1, call: func(arguments []Value) (Value, error) {
+		b, err := toBoolean(arguments[0])
+		if err != nil {
+			return nil, err
+		}
+		return !b, nil
+	}},
+}
+
+// booleanArguments converts arguments to booleans. Values in a range that
+// are not booleans or numbers are skipped.
+func booleanArguments(arguments []Value) ([]Boolean, error) {
+	booleans := make([]Boolean, 0, len(arguments))
+	for _, argument := range arguments {
+		if r, ok := argument.(RangeValue); ok {
+			r.Each(func(value Value) bool {
+				switch value.(type) {
+				case Boolean, Number:
+					b, _ := toBoolean(value)
+					booleans = append(booleans, b)
+				}
+				return true
+			})
+			continue
+		}
+		b, err := toBoolean(argument)
+		if err != nil {
+			return nil, err
+		}
+		booleans = append(booleans, b)
+	}
+	return booleans, nil
+}
+
+// flatten replaces range arguments with the non-empty values they cover.
+func flatten(arguments []Value) []Value {
+	values := make([]Value, 0, len(arguments))
+	for _, argument := range arguments {
+		r, ok := argument.(RangeValue)
+		if !ok {
+			values = append(values, argument)
+			continue
+		}
+		r.Each(func(value Value) bool {
+			values = append(values, value)
+			return true
+		})
+	}
+	return values
+}
+
+// numericArguments converts arguments to numbers. Values in a range that are
+// not numbers are skipped while any other argument must be a number.
+func numericArguments(arguments []Value) ([]Number, error) {
+	numbers := make([]Number, 0, len(arguments))
+	for _, argument := range arguments {
+		if r, ok := argument.(RangeValue); ok {
+			r.Each(func(value Value) bool {
+				if n, ok := value.(Number); ok {
+					numbers = append(numbers, n)
+				}
+				return true
+			})
+			continue
+		}
+		n, err := toNumber(argument)
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
+
+func sum(numbers []Number) (Number, error) {
+	var (
+		total Number
+		err   error
+	)
+	for _, n := range numbers {
+		total, err = total.Add(n)
+		if err != nil {
+			return Number{}, err
+		}
+	}
+	return total, nil
+}
+
+// extreme returns the smallest number when direction is negative and the
+// largest when it is positive. It returns zero when there are no numbers.
+func extreme(numbers []Number, direction int) Number {
+	if len(numbers) == 0 {
+		return Number{}
+	}
+	result := numbers[0]
+	for _, n := range numbers[1:] {
+		if n.Cmp(result)*direction > 0 {
+			result = n
+		}
+	}
+	return result
+}
@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_comparisonsAndLogic(t *testing.T) {
+	var table Table
+	if err := json.Unmarshal([]byte(`{"columns":3,"rows":3,"cells":[
+		{"id":"A0","ex":"5"},
+		{"id":"A1","ex":"\"apple\""},
+		{"id":"A2","ex":"TRUE"},
+		{"id":"B0","ex":"0"}
+	]}`), &table); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		Expression string
+		Result     Value
+	}{
+		{Expression: "1 = 1", Result: Boolean(true)},
+		{Expression: "1 <> 1", Result: Boolean(false)},
+		{Expression: "1 < 2", Result: Boolean(true)},
+		{Expression: "2 <= 2", Result: Boolean(true)},
+		{Expression: "3 > 2", Result: Boolean(true)},
+		{Expression: "2 >= 3", Result: Boolean(false)},
+		{Expression: "0.1 + 0.2 = 0.3", Result: Boolean(true)},
+		{Expression: "A0 * 2 = 10", Result: Boolean(true)},
+		{Expression: "1 = 1 + 2", Result: Boolean(false)},
+		{Expression: `A1 = "APPLE"`, Result: Boolean(true)},
+		{Expression: `"a" < "b"`, Result: Boolean(true)},
+		{Expression: `1 < "a"`, Result: Boolean(true)},
+		{Expression: `"a" = 1`, Result: Boolean(false)},
+		{Expression: "C2 = 0", Result: Boolean(true)},
+		{Expression: `C2 = ""`, Result: Boolean(true)},
+		{Expression: "A2 = TRUE", Result: Boolean(true)},
+		{Expression: "FALSE < TRUE", Result: Boolean(true)},
+		{Expression: "TRUE", Result: Boolean(true)},
+		{Expression: `"is " & (1 < 2)`, Result: Text("is TRUE")},
+		{Expression: "IF(A0 > 3, \"big\", \"small\")", Result: Text("big")},
+		{Expression: "IF(A0 > 30, \"big\", \"small\")", Result: Text("small")},
+		{Expression: "IF(B0, 1, 2)", Result: NewNumber(2)},
+		{Expression: "IF(FALSE, 1)", Result: Boolean(false)},
+		{Expression: "IF(TRUE, 1, 1 / 0)", Result: NewNumber(1)},
+		{Expression: "IF(FALSE, \"a\" + 1, 3)", Result: NewNumber(3)},
+		{Expression: "AND(TRUE, A0 > 1)", Result: Boolean(true)},
+		{Expression: "AND(TRUE, FALSE)", Result: Boolean(false)},
+		{Expression: "AND(A0:A2)", Result: Boolean(true)},
+		{Expression: "AND(A0:B0)", Result: Boolean(false)},
+		{Expression: "OR(FALSE, B0)", Result: Boolean(false)},
+		{Expression: "OR(FALSE, A0 = 5)", Result: Boolean(true)},
+		{Expression: "NOT(A2)", Result: Boolean(false)},
+		{Expression: "NOT(1 > 2)", Result: Boolean(true)},
+	} {
+		t.Run(tt.Expression, func(t *testing.T) {
+			exp, _, err := newExpression(tt.Expression, 2, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			value, err := evaluate(&table, &Cell{Column: 2, Row: 1}, newWalkState(0), exp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != tt.Result {
+				t.Errorf("expected %s %q got %s %q", tt.Result.TypeName(), tt.Result, value.TypeName(), value)
+			}
+		})
+	}
+
+	for _, expression := range []string{
+		"TRUE + 1",
+		`IF("yes", 1, 2)`,
+		`NOT("no")`,
+		`AND(1, "x")`,
+		"IF(TRUE, 1 / 0, 1)",
+	} {
+		t.Run(expression, func(t *testing.T) {
+			exp, _, err := newExpression(expression, 2, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := evaluate(&table, &Cell{}, newWalkState(0), exp); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func Test_IF_onlyFollowsEvaluatedBranch(t *testing.T) {
+	workbook := NewWorkbook(3, 3)
+	setCells(t, workbook, map[string]string{"A0": "0", "B0": "IF(A0 > 0, B0, 1)"})
+	b0 := workbook.sheets[0].Cell(1, 0)
+	if b0.Error != "" {
+		t.Fatalf("unexpected error %s", b0.Error)
+	}
+	if b0.Value != NewNumber(1) {
+		t.Errorf("expected B0 to be 1 got %s", b0.Value)
+	}
+
+	setCells(t, workbook, map[string]string{"A0": "1"})
+	if got := workbook.sheets[0].Cell(1, 0).ErrorKind(); got != string(ErrorCycle) {
+		t.Errorf("expected taking the self referencing branch to be %s got %q", ErrorCycle, got)
+	}
+}
+
+func Test_IF_mutualReferencesOnUntakenBranches(t *testing.T) {
+	var table Table
+	err := json.Unmarshal([]byte(`{"columns":3,"rows":3,"cells":[
+		{"id":"A0","ex":"IF(FALSE, B0, 1)"},
+		{"id":"B0","ex":"IF(TRUE, A0 + 1, B0)"}
+	]}`), &table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := table.Cell(1, 0).Value; got != NewNumber(2) {
+		t.Errorf("expected B0 to be 2 got %s", got)
+	}
+}
+
+func Test_IF_range(t *testing.T) {
+	workbook := NewWorkbook(3, 3)
+	setCells(t, workbook, map[string]string{"A0": "1", "A1": "2", "B0": "IF(A0, A0:A1, 0)", "B1": "SUM(A0:A1, IF(A0, 1, 0))"})
+	sheet := workbook.sheets[0]
+	if got := sheet.Cell(1, 0).ErrorKind(); got != string(ErrorValueType) {
+		t.Errorf("expected a range result to be %s got %q", ErrorValueType, got)
+	}
+	if got := sheet.Cell(1, 1).String(); got != "4" {
+		t.Errorf("expected B1 to be 4 got %s", got)
+	}
+}
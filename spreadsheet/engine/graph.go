@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+)
+
+// Direction selects the edges followed through the dependency graph.
+type Direction string
+
+const (
+	// Precedents are the cells a cell references, directly or through
+	// other cells.
+	Precedents Direction = "precedents"
+
+	// Dependents are the cells referencing a cell, directly or through
+	// other cells.
+	Dependents Direction = "dependents"
+)
+
+// edges returns the function looking up the neighbours of a cell in the
+// direction.
+func (workbook *Workbook) edges(d Direction) func(CellIdentifier) map[CellIdentifier]struct{} {
+	if d == Dependents {
+		return workbook.dependentsOf
+	}
+	return workbook.precedentsOf
+}
+
+// CellGraph holds the cells reached from a cell following the dependency
+// graph in one direction.
+type CellGraph struct {
+	Cell      string      `json:"cell"`
+	Direction Direction   `json:"direction"`
+	Cells     []GraphCell `json:"cells"`
+}
+
+// GraphCell is a cell of a CellGraph. Depth is the number of edges between
+// it and the inspected cell. Edges are its own neighbours in the direction
+// of the graph, the cells it references for precedents and the cells
+// referencing it for dependents. Cells of other sheets are written with
+// the sheet name, like SHEET2!B3.
+type GraphCell struct {
+	ID         string   `json:"id"`
+	Expression string   `json:"ex,omitempty"`
+	Value      string   `json:"value"`
+	Depth      int      `json:"depth"`
+	Edges      []string `json:"edges,omitempty"`
+
+	id CellIdentifier
+}
+
+// Identifier returns the identifier of the cell in the workbook.
+func (cell GraphCell) Identifier() CellIdentifier {
+	return cell.id
+}
+
+// Graph walks the dependency graph from the cell breadth first. The
+// cells are ordered by depth and then by sheet, row and column.
+func (workbook *Workbook) Graph(sheet *Table, column, row int, d Direction) CellGraph {
+	start := sheet.CellID(column, row)
+	graph := CellGraph{Cell: cellReferenceText(column, row, false, false), Direction: d, Cells: []GraphCell{}}
+	edges := workbook.edges(d)
+	seen := map[CellIdentifier]bool{start: true}
+	level := []CellIdentifier{start}
+	for depth := 1; len(level) > 0; depth++ {
+		var next []CellIdentifier
+		for _, id := range level {
+			for neighbour := range edges(id) {
+				if !seen[neighbour] {
+					seen[neighbour] = true
+					next = append(next, neighbour)
+				}
+			}
+		}
+		slices.SortFunc(next, CompareCellIdentifiers)
+		for _, id := range next {
+			graph.Cells = append(graph.Cells, workbook.graphCell(sheet.Key(), id, depth, edges(id)))
+		}
+		level = next
+	}
+	return graph
+}
+
+func (workbook *Workbook) graphCell(sheetKey string, id CellIdentifier, depth int, neighbours map[CellIdentifier]struct{}) GraphCell {
+	cell := GraphCell{ID: globalLabel(sheetKey, id), Depth: depth, id: id}
+	if sheet := workbook.sheetByKey(id.sheet); sheet != nil {
+		c := sheet.Cell(id.column, id.row)
+		cell.Expression = c.ExpressionText()
+		cell.Value = c.String()
+	}
+	for _, neighbour := range slices.SortedFunc(maps.Keys(neighbours), CompareCellIdentifiers) {
+		cell.Edges = append(cell.Edges, globalLabel(sheetKey, neighbour))
+	}
+	return cell
+}
+
+// CompareCellIdentifiers orders cells by sheet, row and column.
+func CompareCellIdentifiers(x, y CellIdentifier) int {
+	return cmp.Or(cmp.Compare(x.sheet, y.sheet), cmp.Compare(x.row, y.row), cmp.Compare(x.column, y.column))
+}
+
+// globalLabel writes the cell like a reference from a cell of the sheet
+// with the key.
+func globalLabel(sheetKey string, id CellIdentifier) string {
+	label := cellReferenceText(id.column, id.row, false, false)
+	if id.sheet != sheetKey {
+		label = id.sheet + "!" + label
+	}
+	return label
+}
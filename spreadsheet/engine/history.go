@@ -0,0 +1,185 @@
+package engine
+
+import (
+	"maps"
+	"slices"
+)
+
+// Change is a reversible edit of a workbook. It holds the previous and the
+// next expression of each cell it changes. Imports may also change the size
+// of a sheet or the list of sheets, and a sheet may be renamed. The methods editing a workbook return
+// the change they made so it can be undone with Apply.
+type Change struct {
+	cells []cellChange
+	sizes []sizeChange
+
+	// previousSheets and nextSheets are set when the list of sheets changed.
+	previousSheets,
+	nextSheets []*Table
+
+	renames []sheetRename
+
+	// names is set when the defined names changed.
+	names *nameChange
+}
+
+type cellChange struct {
+	sheet       *Table
+	column, row int
+	previous,
+	next cellState
+}
+
+type cellState struct {
+	expression ExpressionNode
+	references []CellIdentifier
+}
+
+func savedCellState(cell *Cell) cellState {
+	return cellState{expression: cell.SavedExpression, references: cell.SavedReferences}
+}
+
+func (state cellState) String() string {
+	if state.expression == nil {
+		return ""
+	}
+	return state.expression.String()
+}
+
+type sheetRename struct {
+	sheet *Table
+	previous,
+	next string
+}
+
+type sizeChange struct {
+	sheet *Table
+	previousColumns, previousRows,
+	nextColumns, nextRows int
+}
+
+// Empty reports whether the change leaves the workbook as it was.
+func (c *Change) Empty() bool {
+	return c == nil || (len(c.cells) == 0 && len(c.sizes) == 0 && c.previousSheets == nil && len(c.renames) == 0 && c.names == nil)
+}
+
+// SheetsChanged reports whether the change adds, removes or renames sheets.
+func (c *Change) SheetsChanged() bool {
+	return c != nil && (c.previousSheets != nil || len(c.renames) > 0)
+}
+
+// addCell records the change of a cell unless its expression stayed the
+// same.
+func (c *Change) addCell(sheet *Table, column, row int, previous, next cellState) {
+	if previous.String() == next.String() {
+		return
+	}
+	c.cells = append(c.cells, cellChange{sheet: sheet, column: column, row: row, previous: previous, next: next})
+}
+
+// addSheet records the changes that turn sheet into table.
+func (c *Change) addSheet(sheet, table *Table) {
+	if sheet.ColumnCount != table.ColumnCount || sheet.RowCount != table.RowCount {
+		c.sizes = append(c.sizes, sizeChange{
+			sheet:           sheet,
+			previousColumns: sheet.ColumnCount, previousRows: sheet.RowCount,
+			nextColumns: table.ColumnCount, nextRows: table.RowCount,
+		})
+	}
+	for cell := range sheet.Cells() {
+		c.addCell(sheet, cell.Column, cell.Row, savedCellState(cell), savedCellState(table.Cell(cell.Column, cell.Row)))
+	}
+	for cell := range table.Cells() {
+		if _, ok := sheet.cells[CellIdentifier{column: cell.Column, row: cell.Row}]; !ok {
+			c.addCell(sheet, cell.Column, cell.Row, cellState{}, savedCellState(cell))
+		}
+	}
+}
+
+// ReplaceSheet replaces the size and cells of sheet with those of table,
+// for example after an import, keeping the name of the sheet.
+func (workbook *Workbook) ReplaceSheet(sheet *Table, table *Table) *Change {
+	c := new(Change)
+	c.addSheet(sheet, table)
+	workbook.Apply(c, false)
+	return c
+}
+
+// Replace replaces the sheets of the workbook with those of other. Sheets
+// with the same name are changed in place so the change can be undone.
+func (workbook *Workbook) Replace(other *Workbook) *Change {
+	c := &Change{previousSheets: slices.Clone(workbook.sheets)}
+	for _, sheet := range other.sheets {
+		existing := workbook.Sheet(sheet.Name)
+		if existing == nil {
+			sheet.workbook = workbook
+			c.nextSheets = append(c.nextSheets, sheet)
+			continue
+		}
+		c.addSheet(existing, sheet)
+		c.nextSheets = append(c.nextSheets, existing)
+	}
+	if slices.Equal(c.previousSheets, c.nextSheets) {
+		c.previousSheets, c.nextSheets = nil, nil
+	}
+	if len(workbook.names) > 0 || len(other.names) > 0 {
+		c.names = &nameChange{previous: maps.Clone(workbook.names), next: other.names}
+	}
+	workbook.Apply(c, false)
+	return c
+}
+
+// Apply makes the change or, when undo is set, reverts it. Changes are
+// undone in the reverse order they were made.
+func (workbook *Workbook) Apply(c *Change, undo bool) {
+	if c.previousSheets != nil {
+		sheets := c.nextSheets
+		if undo {
+			sheets = c.previousSheets
+		}
+		for _, sheet := range workbook.sheets {
+			sheet.workbook = nil
+		}
+		workbook.sheets = slices.Clone(sheets)
+		for _, sheet := range workbook.sheets {
+			sheet.workbook = workbook
+		}
+	}
+	for _, rename := range c.renames {
+		rename.sheet.Name = rename.next
+		if undo {
+			rename.sheet.Name = rename.previous
+		}
+	}
+	if c.names != nil {
+		workbook.names = maps.Clone(c.names.next)
+		if undo {
+			workbook.names = maps.Clone(c.names.previous)
+		}
+	}
+	for _, size := range c.sizes {
+		if undo {
+			size.sheet.ColumnCount, size.sheet.RowCount = size.previousColumns, size.previousRows
+		} else {
+			size.sheet.ColumnCount, size.sheet.RowCount = size.nextColumns, size.nextRows
+		}
+	}
+	changed := make([]CellIdentifier, 0, len(c.cells))
+	for _, cellChange := range c.cells {
+		state := cellChange.next
+		if undo {
+			state = cellChange.previous
+		}
+		cell := cellChange.sheet.CellPointer(cellChange.column, cellChange.row)
+		cell.Error = ""
+		cell.input = state.String()
+		cell.Expression = state.expression
+		cell.References = state.references
+		changed = append(changed, cellChange.sheet.CellID(cellChange.column, cellChange.row))
+	}
+	if c.SheetsChanged() || len(c.sizes) > 0 || c.names != nil {
+		workbook.calculateValues()
+		return
+	}
+	workbook.recalculate(changed)
+}
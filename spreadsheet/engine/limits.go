@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"fmt"
+)
+
+// Limits bound the work a formula can cause so a formula like 2^2000000000
+// or a long chain of references can not stall the program calculating it.
+type Limits struct {
+	// FormulaLength is the maximum number of characters of a formula.
+	FormulaLength int
+
+	// NestingDepth is the maximum number of parentheses and function calls
+	// nested inside each other in a formula.
+	NestingDepth int
+
+	// Steps is the number of expressions evaluated in one recalculation.
+	// Cells evaluated after the budget is used up show #CALC!.
+	Steps int
+}
+
+// DefaultLimits are the limits in effect until SetLimits is called.
+var DefaultLimits = Limits{
+	FormulaLength: 8192,
+	NestingDepth:  64,
+	Steps:         1_000_000,
+}
+
+// limits are the limits in effect.
+var limits = DefaultLimits
+
+// SetLimits changes the limits of every workbook and table. Set them before
+// calculating, the limits are not safe to change while an expression is
+// evaluated.
+func SetLimits(l Limits) error {
+	if err := l.check(); err != nil {
+		return err
+	}
+	limits = l
+	return nil
+}
+
+// CurrentLimits returns the limits in effect.
+func CurrentLimits() Limits {
+	return limits
+}
+
+func (l Limits) check() error {
+	switch {
+	case l.FormulaLength < 1:
+		return fmt.Errorf("the maximum formula length must be at least 1 got %d", l.FormulaLength)
+	case l.NestingDepth < 1:
+		return fmt.Errorf("the maximum nesting depth must be at least 1 got %d", l.NestingDepth)
+	case l.Steps < 1:
+		return fmt.Errorf("the evaluation step budget must be at least 1 got %d", l.Steps)
+	}
+	return nil
+}
+
+// budget counts the evaluation steps left in a recalculation. It is shared
+// by the copies of a walkState.
+type budget struct {
+	steps, limit int
+}
+
+func newBudget(steps int) *budget {
+	return &budget{steps: steps, limit: steps}
+}
+
+// step uses one step of the budget. It fails once the budget is used up.
+// A nil budget has no limit.
+func (b *budget) step() error {
+	if b == nil {
+		return nil
+	}
+	if b.steps <= 0 {
+		return newErrorValue(ErrorCalculation, "the recalculation took more than %d steps", b.limit)
+	}
+	b.steps--
+	return nil
+}
@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+func setLimits(t *testing.T, l Limits) {
+	t.Helper()
+	previous := limits
+	limits = l
+	t.Cleanup(func() { limits = previous })
+}
+
+func TestNumber_Pow(t *testing.T) {
+	for _, tt := range []struct {
+		Expression string
+		Result     string
+	}{
+		{Expression: "2 ^ 10", Result: "1024"},
+		{Expression: "2 ^ 62", Result: "4611686018427387904"},
+		{Expression: "(-2) ^ 3", Result: "-8"},
+		{Expression: "0.5 ^ 3", Result: "0.125"},
+		{Expression: "0 ^ 0", Result: "1"},
+		{Expression: "0 ^ 2", Result: "0"},
+		{Expression: "1 ^ 2000000000", Result: "1"},
+		{Expression: "0.5 ^ 2000000000", Result: "0"},
+	} {
+		t.Run(tt.Expression, func(t *testing.T) {
+			exp, _, err := newExpression(tt.Expression, 9, 9)
+			if err != nil {
+				t.Fatal(err)
+			}
+			table := NewTable(10, 10)
+			value, err := evaluate(&table, &Cell{}, newWalkState(0), exp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := value.String(); got != tt.Result {
+				t.Errorf("expected %s got %s", tt.Result, got)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		Expression string
+		Message    string
+	}{
+		{Expression: "2 ^ 63", Message: "overflow"},
+		{Expression: "2 ^ 2000000000", Message: "overflow"},
+		{Expression: "2 ^ -1", Message: "must not be negative"},
+		{Expression: "-9223372036854775807 - 1", Message: "overflow"},
+		{Expression: "3037000500 * 3037000500", Message: "overflow"},
+	} {
+		t.Run(tt.Expression, func(t *testing.T) {
+			exp, _, err := newExpression(tt.Expression, 9, 9)
+			if err != nil {
+				t.Fatal(err)
+			}
+			table := NewTable(10, 10)
+			_, err = evaluate(&table, &Cell{}, newWalkState(0), exp)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errorValue := toErrorValue(err); errorValue.Kind != ErrorNumber || !strings.Contains(errorValue.Message, tt.Message) {
+				t.Errorf("expected a %s error containing %q got %v", ErrorNumber, tt.Message, err)
+			}
+		})
+	}
+}
+
+func TestLimits_steps(t *testing.T) {
+	setLimits(t, Limits{FormulaLength: 100, NestingDepth: 10, Steps: 10})
+
+	workbook := NewWorkbook(1, 10)
+	cells := map[string]string{"A0": "1"}
+	for row := 1; row < 10; row++ {
+		cells[cellReferenceText(0, row, false, false)] = cellReferenceText(0, row-1, false, false) + " + 1"
+	}
+	setCells(t, workbook, cells)
+
+	sheet := workbook.sheets[0]
+	if got := sheet.Cell(0, 3).String(); got != "4" {
+		t.Errorf("expected the cells in the budget to be calculated got %s", got)
+	}
+	last := sheet.Cell(0, 9)
+	if last.ErrorKind() != string(ErrorCalculation) || !strings.Contains(last.ErrorMessage(), "more than 10 steps") {
+		t.Errorf("expected A9 to be out of steps got %s %s", last.ErrorKind(), last.ErrorMessage())
+	}
+
+	// each recalculation gets a new budget
+	limits.Steps = 100
+	setCells(t, workbook, map[string]string{"A0": "2"})
+	if got := sheet.Cell(0, 9).String(); got != "11" {
+		t.Errorf("expected A9 to be calculated got %s", got)
+	}
+}
+
+func TestLimits_rangeSteps(t *testing.T) {
+	workbook := NewWorkbook(2, 100)
+	cells := make(map[string]string)
+	for row := range 100 {
+		cells[cellReferenceText(0, row, false, false)] = "1"
+	}
+	setCells(t, workbook, cells)
+
+	// walking the cells of a range uses the budget
+	setLimits(t, Limits{FormulaLength: 100, NestingDepth: 10, Steps: 50})
+	setCells(t, workbook, map[string]string{"B0": "SUM(A0:A99)"})
+	if got := workbook.sheets[0].Cell(1, 0).ErrorKind(); got != string(ErrorCalculation) {
+		t.Errorf("expected the range to use up the budget got %q", got)
+	}
+}
+
+func TestLimits_negativeReferences(t *testing.T) {
+	table := NewTable(3, 3)
+	for _, node := range []ExpressionNode{
+		IdentifierNode{Column: -1},
+		FunctionNode{Name: Token{Value: "SUM"}, Arguments: []ExpressionNode{RangeNode{From: IdentifierNode{Column: -6696602603409169451}, To: IdentifierNode{Column: 1}}}},
+	} {
+		_, err := evaluate(&table, &Cell{}, newWalkState(0), node)
+		if got := toErrorValue(err).Kind; got != ErrorReference {
+			t.Errorf("expected %#v to be %s got %v", node, ErrorReference, err)
+		}
+	}
+}
+
+func TestLimits_parse(t *testing.T) {
+	setLimits(t, Limits{FormulaLength: 20, NestingDepth: 2, Steps: 100})
+
+	for _, expression := range []string{"SUM(1, (2))", "((1)) + (2)", strings.Repeat("1+", 9) + "1"} {
+		if _, _, err := newExpression(expression, 9, 9); err != nil {
+			t.Errorf("expected %s to be in the limits got %s", expression, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		Expression string
+		Message    string
+	}{
+		{Expression: strings.Repeat("1+", 10) + "1", Message: "21 characters long it must be at most 20"},
+		{Expression: "(((1)))", Message: "nested deeper than 2"},
+		{Expression: "SUM(SUM(SUM(1)))", Message: "nested deeper than 2"},
+	} {
+		t.Run(tt.Expression, func(t *testing.T) {
+			_, _, err := newExpression(tt.Expression, 9, 9)
+			if err == nil || !strings.Contains(err.Error(), tt.Message) {
+				t.Errorf("expected an error containing %q got %v", tt.Message, err)
+			}
+		})
+	}
+
+	// a formula over the limits is reported on the cell
+	workbook := NewWorkbook(2, 2)
+	setCells(t, workbook, map[string]string{"A0": "(((1)))"})
+	if got := workbook.sheets[0].Cell(0, 0).Error; !strings.Contains(got, "nested deeper than 2") {
+		t.Errorf("expected the cell to show the error got %q", got)
+	}
+}
+
+func TestLimits_check(t *testing.T) {
+	if err := DefaultLimits.check(); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []Limits{
+		{FormulaLength: 0, NestingDepth: 1, Steps: 1},
+		{FormulaLength: 1, NestingDepth: 0, Steps: 1},
+		{FormulaLength: 1, NestingDepth: 1, Steps: -1},
+	} {
+		if err := l.check(); err == nil {
+			t.Errorf("expected %+v to be rejected", l)
+		}
+	}
+}
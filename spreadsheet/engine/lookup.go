@@ -0,0 +1,248 @@
+package engine
+
+import (
+	"cmp"
+	"iter"
+	"slices"
+)
+
+// Positions in a range count from 1 like in other spreadsheets, so
+// INDEX(A0:A9, 1) is the value of A0 and the formulas read the same in
+// Excel.
+
+// matchType selects how a lookup compares values.
+type matchType int
+
+const (
+	// matchLessOrEqual finds the largest value less than or equal to the
+	// lookup value in values sorted in ascending order.
+	matchLessOrEqual matchType = 1
+
+	// matchExact finds the first value equal to the lookup value.
+	matchExact matchType = 0
+
+	// matchGreaterOrEqual finds the smallest value greater than or equal to
+	// the lookup value in values sorted in descending order.
+	matchGreaterOrEqual matchType = -1
+)
+
+// rangeArgument returns the range of a lookup argument. A single value is
+// a range of one cell.
+func rangeArgument(value Value) RangeValue {
+	if r, ok := value.(RangeValue); ok {
+		return r
+	}
+	r := RangeValue{Rows: 1, Columns: 1}
+	if value != nil {
+		r.cells = []rangeCell{{value: value}}
+	}
+	return r
+}
+
+// row returns the position in the row and the value of each stored cell in
+// the row of the range. Empty cells are skipped so a search over a large
+// range only visits the cells that are set.
+func (value RangeValue) row(index int) iter.Seq2[int, Value] {
+	return func(yield func(int, Value) bool) {
+		start, _ := slices.BinarySearchFunc(value.cells, index, func(c rangeCell, row int) int {
+			return cmp.Compare(c.row, row)
+		})
+		for _, c := range value.cells[start:] {
+			if c.row != index || !yield(c.column, c.value) {
+				return
+			}
+		}
+	}
+}
+
+// column returns the position in the column and the value of each stored
+// cell in the column of the range.
+func (value RangeValue) column(index int) iter.Seq2[int, Value] {
+	return func(yield func(int, Value) bool) {
+		for _, c := range value.cells {
+			if c.column == index && !yield(c.row, c.value) {
+				return
+			}
+		}
+	}
+}
+
+// position converts a function argument to a position in a range counting
+// from 1. It must be at least 1 and at most count.
+func position(value Value, what string, count int) (int, error) {
+	n, err := toNumber(value)
+	if err != nil {
+		return 0, err
+	}
+	i, ok := n.Int()
+	if !ok || i < 1 {
+		return 0, newErrorValue(ErrorValueType, "the %s %s must be a whole number greater than 0", what, n)
+	}
+	if i > count {
+		return 0, newErrorValue(ErrorReference, "the %s %d is outside of the range, it has %d", what, i, count)
+	}
+	return i, nil
+}
+
+// lookupMatch returns the index of the value matching lookup in values
+// ordered by index. Empty cells never match.
+func lookupMatch(lookup Value, values iter.Seq2[int, Value], match matchType) (int, bool) {
+	found := -1
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		order := compareOrder(value, lookup)
+		switch match {
+		case matchExact:
+			if order == 0 {
+				return i, true
+			}
+			continue
+		case matchGreaterOrEqual:
+			order = -order
+		}
+		if typeOrder(value) != typeOrder(lookup) {
+			continue
+		}
+		if order > 0 {
+			// the values are sorted so no later value matches
+			break
+		}
+		found = i
+	}
+	return found, found >= 0
+}
+
+func notFound(lookup Value, r RangeValue) ErrorValue {
+	if r.Node.From.Token.Value == "" {
+		return newErrorValue(ErrorNotAvailable, "%s was not found", quoteValue(lookup))
+	}
+	return newErrorValue(ErrorNotAvailable, "%s was not found in %s", quoteValue(lookup), r)
+}
+
+// lookupValue returns the value searched for. An empty cell is searched
+// for as zero.
+func lookupValue(lookup Value) (Value, error) {
+	switch lookup.(type) {
+	case nil:
+		return Number{}, nil
+	case RangeValue:
+		return nil, TypeError{Expected: "a single value", Got: lookup}
+	}
+	return lookup, nil
+}
+
+// index implements INDEX(range, row, [column]). A range of one row is
+// indexed by column when only one position is given.
+func index(arguments []Value) (Value, error) {
+	r := rangeArgument(arguments[0])
+	if len(arguments) == 2 {
+		if r.Rows == 1 {
+			column, err := position(arguments[1], "column", r.Columns)
+			if err != nil {
+				return nil, err
+			}
+			return r.At(0, column-1), nil
+		}
+		arguments = append(arguments, NewNumber(1))
+	}
+	row, err := position(arguments[1], "row", r.Rows)
+	if err != nil {
+		return nil, err
+	}
+	column, err := position(arguments[2], "column", r.Columns)
+	if err != nil {
+		return nil, err
+	}
+	return r.At(row-1, column-1), nil
+}
+
+// match implements MATCH(value, range, [type]). It returns the position of
+// the matching value in a range of one row or one column.
+func match(arguments []Value) (Value, error) {
+	r := rangeArgument(arguments[1])
+	var values iter.Seq2[int, Value]
+	switch {
+	case r.Rows == 1:
+		values = r.row(0)
+	case r.Columns == 1:
+		values = r.column(0)
+	default:
+		return nil, newErrorValue(ErrorValueType, "MATCH searches one row or one column but %s has %d rows and %d columns", r, r.Rows, r.Columns)
+	}
+	kind := matchLessOrEqual
+	if len(arguments) == 3 {
+		n, err := toNumber(arguments[2])
+		if err != nil {
+			return nil, err
+		}
+		kind = matchType(n.Sign())
+	}
+	lookup, err := lookupValue(arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	i, ok := lookupMatch(lookup, values, kind)
+	if !ok {
+		return nil, notFound(lookup, r)
+	}
+	return NewNumber(i + 1), nil
+}
+
+// vlookup implements VLOOKUP(value, range, column, [approximate]). It
+// searches the first column of the range and returns the value in the
+// column of the matching row.
+func vlookup(arguments []Value) (Value, error) {
+	r := rangeArgument(arguments[1])
+	column, err := position(arguments[2], "column", r.Columns)
+	if err != nil {
+		return nil, err
+	}
+	i, err := tableLookup(arguments, r, r.column(0))
+	if err != nil {
+		return nil, err
+	}
+	return r.At(i, column-1), nil
+}
+
+// hlookup implements HLOOKUP(value, range, row, [approximate]). It
+// searches the first row of the range and returns the value in the row of
+// the matching column.
+func hlookup(arguments []Value) (Value, error) {
+	r := rangeArgument(arguments[1])
+	row, err := position(arguments[2], "row", r.Rows)
+	if err != nil {
+		return nil, err
+	}
+	i, err := tableLookup(arguments, r, r.row(0))
+	if err != nil {
+		return nil, err
+	}
+	return r.At(row-1, i), nil
+}
+
+// tableLookup finds the lookup value in the values searched by VLOOKUP
+// and HLOOKUP. The search is approximate unless the fourth argument is
+// FALSE.
+func tableLookup(arguments []Value, r RangeValue, values iter.Seq2[int, Value]) (int, error) {
+	kind := matchLessOrEqual
+	if len(arguments) == 4 {
+		approximate, err := toBoolean(arguments[3])
+		if err != nil {
+			return 0, err
+		}
+		if !approximate {
+			kind = matchExact
+		}
+	}
+	lookup, err := lookupValue(arguments[0])
+	if err != nil {
+		return 0, err
+	}
+	i, ok := lookupMatch(lookup, values, kind)
+	if !ok {
+		return 0, notFound(lookup, r)
+	}
+	return i, nil
+}
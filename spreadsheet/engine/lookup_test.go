@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"encoding/json"
+	"runtime"
+	"testing"
+)
+
+func Test_lookupFunctions(t *testing.T) {
+	var table Table
+	if err := json.Unmarshal([]byte(`{"columns":5,"rows":6,"cells":[
+		{"id":"A0","ex":"0"},   {"id":"B0","ex":"\"small\""},  {"id":"C0","ex":"\"apple\""},  {"id":"D0","ex":"1.5"}, {"id":"E0","ex":"100"},
+		{"id":"A1","ex":"10"},  {"id":"B1","ex":"\"medium\""}, {"id":"C1","ex":"\"banana\""}, {"id":"D1","ex":"2"},   {"id":"E1","ex":"50"},
+		{"id":"A2","ex":"50"},  {"id":"B2","ex":"\"large\""},  {"id":"C2","ex":"\"cherry\""}, {"id":"D2","ex":"3"},   {"id":"E2","ex":"10"},
+		{"id":"A3","ex":"100"}, {"id":"B3","ex":"\"bulk\""},                                                            {"id":"E3","ex":"0"},
+		{"id":"A4","ex":"\"Q1\""}, {"id":"B4","ex":"\"Q2\""}, {"id":"C4","ex":"\"Q3\""},
+		{"id":"A5","ex":"100"},    {"id":"B5","ex":"200"},    {"id":"C5","ex":"300"}
+	]}`), &table); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		Expression string
+		Result     Value
+	}{
+		{Expression: "VLOOKUP(25, A0:B3, 2)", Result: Text("medium")},
+		{Expression: "VLOOKUP(10, A0:B3, 2, TRUE)", Result: Text("medium")},
+		{Expression: "VLOOKUP(1000, A0:B3, 2)", Result: Text("bulk")},
+		{Expression: "VLOOKUP(50, A0:B3, 1, FALSE)", Result: NewNumber(50)},
+		{Expression: `VLOOKUP("Banana", C0:D2, 2, FALSE)`, Result: NewNumber(2)},
+		{Expression: `HLOOKUP("q2", A4:C5, 2, FALSE)`, Result: NewNumber(200)},
+		{Expression: `HLOOKUP("Q25", A4:C5, 2)`, Result: NewNumber(200)},
+		{Expression: "MATCH(50, A0:A3, 0)", Result: NewNumber(3)},
+		{Expression: "MATCH(60, A0:A3)", Result: NewNumber(3)},
+		{Expression: "MATCH(60, A0:A3, 1)", Result: NewNumber(3)},
+		{Expression: "MATCH(30, E0:E3, -1)", Result: NewNumber(2)},
+		{Expression: `MATCH("cherry", C0:C3, 0)`, Result: NewNumber(3)},
+		{Expression: `MATCH("Q3", A4:C4, 0)`, Result: NewNumber(3)},
+		{Expression: "INDEX(A0:B3, 2, 2)", Result: Text("medium")},
+		{Expression: "INDEX(D0:D2, 3)", Result: NewNumber(3)},
+		{Expression: "INDEX(A4:C4, 2)", Result: Text("Q2")},
+		{Expression: `INDEX(C0:D2, MATCH("cherry", C0:C2, 0), 2)`, Result: NewNumber(3)},
+	} {
+		t.Run(tt.Expression, func(t *testing.T) {
+			exp, _, err := newExpression(tt.Expression, 4, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			value, err := evaluate(&table, &Cell{}, newWalkState(0), exp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != tt.Result {
+				t.Errorf("expected %s %q got %s %q", tt.Result.TypeName(), tt.Result, value.TypeName(), value)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		Expression string
+		Kind       ErrorKind
+		Message    string
+	}{
+		{Expression: "VLOOKUP(-1, A0:B3, 2)", Kind: ErrorNotAvailable, Message: "-1 was not found in A0:B3"},
+		{Expression: `VLOOKUP("kiwi", C0:D2, 2, FALSE)`, Kind: ErrorNotAvailable, Message: `"kiwi" was not found in C0:D2`},
+		{Expression: "MATCH(5, A0:A3, 0)", Kind: ErrorNotAvailable},
+		{Expression: "MATCH(200, E0:E3, -1)", Kind: ErrorNotAvailable},
+		{Expression: "MATCH(1, A0:B3)", Kind: ErrorValueType},
+		{Expression: "MATCH(A0:A1, A0:A3)", Kind: ErrorValueType},
+		{Expression: "INDEX(A0:B3, 5, 1)", Kind: ErrorReference},
+		{Expression: "INDEX(A0:B3, 0, 1)", Kind: ErrorValueType},
+		{Expression: "INDEX(A0:B3, 1.5, 1)", Kind: ErrorValueType},
+		{Expression: "VLOOKUP(1, A0:B3, 3)", Kind: ErrorReference},
+		{Expression: "HLOOKUP(1, A4:C5, 3)", Kind: ErrorReference},
+		{Expression: "#N/A", Kind: ErrorNotAvailable},
+	} {
+		t.Run(tt.Expression, func(t *testing.T) {
+			exp, _, err := newExpression(tt.Expression, 4, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = evaluate(&table, &Cell{}, newWalkState(0), exp)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			got := toErrorValue(err)
+			if got.Kind != tt.Kind || (tt.Message != "" && got.Message != tt.Message) {
+				t.Errorf("expected %s %q got %s %q", tt.Kind, tt.Message, got.Kind, got.Message)
+			}
+		})
+	}
+}
+
+func Test_lookupFunctions_dependencies(t *testing.T) {
+	workbook := NewWorkbook(4, 4)
+	setCells(t, workbook, map[string]string{"A0": "1", "B0": "10", "A1": "2", "C0": "VLOOKUP(2, A0:B2, 2, FALSE)"})
+	sheet := workbook.sheets[0]
+	if got := sheet.Cell(2, 0).String(); got != "0" {
+		t.Errorf("expected the empty cell next to the match to be 0 got %s", got)
+	}
+
+	// the searched range is a single edge covering the empty cells too
+	if got := len(workbook.rangeDependents.ranges[sheet.CellID(2, 0)]); got != 1 {
+		t.Errorf("expected 1 range got %d", got)
+	}
+	setCells(t, workbook, map[string]string{"B1": "20"})
+	if got := sheet.Cell(2, 0).String(); got != "20" {
+		t.Errorf("expected the lookup to be calculated again got %s", got)
+	}
+	setCells(t, workbook, map[string]string{"A1": "5"})
+	if got := sheet.Cell(2, 0); got.ErrorKind() != string(ErrorNotAvailable) || got.ErrorMessage() != "2 was not found in A0:B2" {
+		t.Errorf("expected %s when nothing matches got %q %q", ErrorNotAvailable, got.ErrorKind(), got.ErrorMessage())
+	}
+}
+
+func Test_lookupFunctions_largeRange(t *testing.T) {
+	r := RangeValue{Rows: 1_000_000, Columns: 2, cells: []rangeCell{
+		{row: 0, column: 0, value: NewNumber(1)}, {row: 0, column: 1, value: Text("one")},
+		{row: 999_999, column: 0, value: NewNumber(2)}, {row: 999_999, column: 1, value: Text("two")},
+	}}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	got, err := vlookup([]Value{NewNumber(2), r, NewNumber(2), Boolean(false)})
+	runtime.ReadMemStats(&after)
+	if err != nil || got != Text("two") {
+		t.Errorf("expected two got %v %v", got, err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<10 {
+		t.Errorf("expected the lookup to only visit the stored cells got %d bytes allocated", allocated)
+	}
+}
@@ -0,0 +1,273 @@
+package engine
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// NameNode is a defined name, like TAX_RATE, used in an expression. The
+// name is looked up in the workbook when the expression is evaluated so an
+// unknown name evaluates to #NAME?.
+type NameNode struct {
+	Token Token
+}
+
+func (node NameNode) String() string {
+	return node.Token.Value
+}
+
+// Name is a defined name and the cell or range it refers to.
+type Name struct {
+	Name, Reference string
+}
+
+var (
+	namePattern = regexp.MustCompile("^[A-Z_][A-Z0-9_]*$")
+
+	// cellLikePattern matches identifiers that start like a cell reference.
+	// They are parsed as cell references so they can not be names.
+	cellLikePattern = regexp.MustCompile(`^\$?[A-Z]+\$?[0-9]`)
+)
+
+// variableIdents are the identifiers the parser reads as variables or
+// booleans.
+var variableIdents = []string{RowIdent, ColumnIdent, MaxRowIdent, MaxColumnIdent, MinRowIdent, MinColumnIdent, TrueIdent, FalseIdent}
+
+// isName reports whether an identifier in an expression is a name.
+func isName(identifier string) bool {
+	return namePattern.MatchString(identifier) && !cellLikePattern.MatchString(identifier)
+}
+
+func checkName(name string) error {
+	switch {
+	case !isName(name):
+		return fmt.Errorf("name %q must start with a letter or underscore, only contain letters, digits and underscores and not start like a cell reference", name)
+	case slices.Contains(variableIdents, name):
+		return fmt.Errorf("name %s is a variable", name)
+	}
+	if _, ok := functions[name]; ok {
+		return fmt.Errorf("name %s is a function", name)
+	}
+	return nil
+}
+
+// parseNameReference parses the cell or range a name refers to. A reference
+// without a sheet refers to the sheet. The reference must be inside the
+// sheet it refers to.
+func (workbook *Workbook) parseNameReference(reference string, sheet *Table) (ExpressionNode, error) {
+	node, _, err := newExpression(reference, sheet.ColumnCount-1, sheet.RowCount-1)
+	if err != nil {
+		return nil, err
+	}
+	switch node := node.(type) {
+	case IdentifierNode:
+		if node.Sheet == "" {
+			node.Sheet = sheet.Key()
+		}
+		if err := workbook.checkNameBounds(node.Sheet, node, node.Column, node.Row); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case RangeNode:
+		if node.From.Sheet == "" {
+			node.From.Sheet, node.To.Sheet = sheet.Key(), sheet.Key()
+		}
+		_, _, maxColumn, maxRow := node.Bounds()
+		if err := workbook.checkNameBounds(node.From.Sheet, node, maxColumn, maxRow); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case ErrorNode:
+		// a name whose cells were deleted
+		return node, nil
+	default:
+		return nil, fmt.Errorf("%s must be a cell or a range", reference)
+	}
+}
+
+// checkNameBounds reports an error when the sheet identified by key does
+// not exist or does not have the cell at column and row.
+func (workbook *Workbook) checkNameBounds(key string, reference ExpressionNode, column, row int) error {
+	sheet := workbook.sheetByKey(key)
+	if sheet == nil {
+		return fmt.Errorf("sheet %s not found", key)
+	}
+	if column >= sheet.ColumnCount || row >= sheet.RowCount {
+		return fmt.Errorf("%s is outside of sheet %s", reference, sheet.Name)
+	}
+	return nil
+}
+
+// Names returns the defined names ordered by name.
+func (workbook *Workbook) Names() []Name {
+	names := make([]Name, 0, len(workbook.names))
+	for _, name := range slices.Sorted(maps.Keys(workbook.names)) {
+		names = append(names, Name{Name: name, Reference: workbook.names[name].String()})
+	}
+	return names
+}
+
+// name returns the cell or range the name refers to.
+func (table *Table) name(name string) (ExpressionNode, error) {
+	if table.workbook != nil {
+		if reference, ok := table.workbook.names[name]; ok {
+			return reference, nil
+		}
+	}
+	return nil, newErrorValue(ErrorName, "unknown name %s", name)
+}
+
+// nameReferences returns the cells referenced through the names used in the
+// expression so the dependency graph has an edge to each of them. Names
+// referring to ranges are linked by Workbook.ranges.
+func (workbook *Workbook) nameReferences(expression ExpressionNode) []CellIdentifier {
+	var references []CellIdentifier
+	mapReferences(expression, func(node ExpressionNode) ExpressionNode {
+		if name, ok := node.(NameNode); ok {
+			if reference, ok := workbook.names[name.Token.Value].(IdentifierNode); ok {
+				references = append(references, CellIdentifier{sheet: reference.Sheet, column: reference.Column, row: reference.Row})
+			}
+		}
+		return node
+	})
+	return references
+}
+
+// nameChange is the change of the defined names of a workbook.
+type nameChange struct {
+	previous, next map[string]ExpressionNode
+}
+
+// SetName defines a name or, when previous is set, changes the name and the
+// reference of the name previous. Formulas using a renamed name are
+// rewritten to use the new name.
+func (workbook *Workbook) SetName(previous, name, reference string, sheet *Table) (*Change, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	previous = strings.ToUpper(previous)
+	if previous != "" {
+		if _, ok := workbook.names[previous]; !ok {
+			return nil, fmt.Errorf("name %s not found", previous)
+		}
+	}
+	if _, exists := workbook.names[name]; exists && name != previous {
+		return nil, fmt.Errorf("name %s already exists", name)
+	}
+	node, err := workbook.parseNameReference(reference, sheet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse reference of name %s: %w", name, err)
+	}
+
+	c := &Change{names: &nameChange{previous: maps.Clone(workbook.names), next: maps.Clone(workbook.names)}}
+	if c.names.next == nil {
+		c.names.next = make(map[string]ExpressionNode)
+	}
+	delete(c.names.next, previous)
+	c.names.next[name] = node
+	if previous != "" && previous != name {
+		if err := workbook.renameInFormulas(c, previous, name); err != nil {
+			return nil, err
+		}
+	}
+	workbook.Apply(c, false)
+	return c, nil
+}
+
+// renameInFormulas adds the rewrite of each formula using the name previous
+// to the change.
+func (workbook *Workbook) renameInFormulas(c *Change, previous, name string) error {
+	for _, sheet := range workbook.sheets {
+		for cell := range sheet.Cells() {
+			if cell.SavedExpression == nil {
+				continue
+			}
+			used := false
+			renamed := mapReferences(cell.SavedExpression, func(node ExpressionNode) ExpressionNode {
+				if n, ok := node.(NameNode); ok && n.Token.Value == previous {
+					used = true
+					n.Token = Token{Type: TokenIdentifier, Value: name}
+					return n
+				}
+				return node
+			})
+			if !used {
+				continue
+			}
+			exp, refs, err := newExpression(renamed.String(), sheet.ColumnCount-1, sheet.RowCount-1)
+			if err != nil {
+				return err
+			}
+			c.addCell(sheet, cell.Column, cell.Row, savedCellState(cell), cellState{expression: exp, references: refs})
+		}
+	}
+	return nil
+}
+
+// DeleteName removes a name. Formulas using it evaluate to #NAME? until the
+// name is defined again.
+func (workbook *Workbook) DeleteName(name string) (*Change, error) {
+	name = strings.ToUpper(name)
+	if _, ok := workbook.names[name]; !ok {
+		return nil, fmt.Errorf("name %s not found", name)
+	}
+	c := &Change{names: &nameChange{previous: maps.Clone(workbook.names), next: maps.Clone(workbook.names)}}
+	delete(c.names.next, name)
+	workbook.Apply(c, false)
+	return c, nil
+}
+
+// shiftNames moves the references of the names when a row or column of the
+// sheet is inserted or deleted.
+func (workbook *Workbook) shiftNames(c *Change, sheet *Table, a Axis, index, delta int) {
+	if len(workbook.names) == 0 {
+		return
+	}
+	next := make(map[string]ExpressionNode, len(workbook.names))
+	for name, reference := range workbook.names {
+		next[name] = mapReferences(reference, func(node ExpressionNode) ExpressionNode {
+			return shiftReference(node, a, index, delta, false, sheet.Key())
+		})
+	}
+	c.names = &nameChange{previous: maps.Clone(workbook.names), next: next}
+}
+
+type EncodedName struct {
+	Name      string `json:"name"`
+	Reference string `json:"ref"`
+}
+
+func (workbook *Workbook) encodeNames() []EncodedName {
+	var encoded []EncodedName
+	for _, name := range workbook.Names() {
+		encoded = append(encoded, EncodedName(name))
+	}
+	return encoded
+}
+
+// decodeNames sets the names of a workbook whose sheets are decoded.
+func (workbook *Workbook) decodeNames(encoded []EncodedName) error {
+	workbook.names = nil
+	for _, name := range encoded {
+		key := strings.ToUpper(name.Name)
+		if err := checkName(key); err != nil {
+			return err
+		}
+		if _, exists := workbook.names[key]; exists {
+			return fmt.Errorf("name %s already exists", key)
+		}
+		node, err := workbook.parseNameReference(name.Reference, workbook.sheets[0])
+		if err != nil {
+			return fmt.Errorf("name %s: %w", key, err)
+		}
+		if workbook.names == nil {
+			workbook.names = make(map[string]ExpressionNode)
+		}
+		workbook.names[key] = node
+	}
+	return nil
+}
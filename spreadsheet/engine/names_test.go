@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func Test_names_parse(t *testing.T) {
+	exp, refs, err := newExpression("TAX_RATE * A1B", 9, 9)
+	if err == nil {
+		t.Fatalf("expected identifiers starting like a cell to be parsed as cells got %s %v", exp, refs)
+	}
+	exp, refs, err = newExpression("tax_rate * 2", 9, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := exp.(BinaryExpressionNode).Left.(NameNode); !ok || len(refs) != 0 {
+		t.Errorf("expected a name got %#v %v", exp, refs)
+	}
+	table := NewTable(1, 1)
+	table.SetCell(Cell{Expression: exp, SavedExpression: exp})
+	table.calculateValues()
+	if got := table.Cell(0, 0).ErrorKind(); got != string(ErrorName) {
+		t.Errorf("expected an unknown name to be %s got %q", ErrorName, got)
+	}
+}
+
+func TestWorkbook_names_JSON(t *testing.T) {
+	workbook := NewWorkbook(2, 3)
+	if _, err := workbook.SetName("", "TOTAL", "A2", workbook.Sheets()[0]); err != nil {
+		t.Fatal(err)
+	}
+	setCells(t, workbook, map[string]string{"A2": "7", "B0": "TOTAL * 2"})
+
+	buf, err := json.Marshal(workbook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(buf), `"names":[{"name":"TOTAL","ref":"SHEET1!A2"}]`) {
+		t.Errorf("expected the names to be saved: %s", buf)
+	}
+	loaded, err := ParseWorkbook(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.sheets[0].Cell(1, 0).String(); got != "14" {
+		t.Errorf("expected the loaded name to be used got %s", got)
+	}
+
+	// the name follows its cell when a row is inserted above it
+	if _, err := workbook.Resize(workbook.Sheets()[0], RowAxis, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := workbook.Names(); len(got) != 1 || got[0].Reference != "SHEET1!A3" {
+		t.Errorf("expected the name to move with its cell got %v", got)
+	}
+	if got := workbook.sheets[0].Cell(1, 1).String(); got != "14" {
+		t.Errorf("expected B1 to still be 14 got %s", got)
+	}
+}
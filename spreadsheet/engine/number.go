@@ -0,0 +1,316 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// decimalPrecision is the maximum number of digits kept after the decimal
+// point. Results with more digits, for example 1 / 3, are rounded half away
+// from zero. Large numbers keep fewer digits so the coefficient fits.
+const decimalPrecision = 10
+
+// Number is an exact decimal number with the value coefficient × 10^-scale.
+// It is kept normalized so that equal numbers have equal representations and
+// can be compared with ==.
+type Number struct {
+	coefficient int64
+	scale       int32
+}
+
+type ErrNumberOverflow struct{}
+
+func (ErrNumberOverflow) Error() string { return "number overflow" }
+
+type ErrDivideByZero struct{}
+
+func (ErrDivideByZero) Error() string { return "could not divide by zero" }
+
+func NewNumber(n int) Number {
+	return Number{coefficient: int64(n)}
+}
+
+// ParseNumber parses a decimal literal like "12", "-3.5" or "0.25".
+func ParseNumber(in string) (Number, error) {
+	text := strings.TrimSpace(in)
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(text, "-")
+	integerPart, fractionPart, _ := strings.Cut(text, ".")
+	if integerPart == "" && fractionPart == "" {
+		return Number{}, fmt.Errorf("failed to parse number %q", in)
+	}
+	for _, c := range integerPart + fractionPart {
+		if c < '0' || c > '9' {
+			return Number{}, fmt.Errorf("failed to parse number %q", in)
+		}
+	}
+	coefficient, ok := new(big.Int).SetString(digitsOrZero(integerPart+fractionPart), 10)
+	if !ok {
+		return Number{}, fmt.Errorf("failed to parse number %q", in)
+	}
+	if negative {
+		coefficient.Neg(coefficient)
+	}
+	n, err := newNumberFromBig(coefficient, int32(len(fractionPart)))
+	if err != nil {
+		return Number{}, fmt.Errorf("failed to parse number %q: %w", in, err)
+	}
+	return n, nil
+}
+
+func digitsOrZero(digits string) string {
+	if digits == "" {
+		return "0"
+	}
+	return digits
+}
+
+var bigTen = big.NewInt(10)
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+// roundedQuotient divides a by b rounding half away from zero.
+func roundedQuotient(a, b *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(a, b, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	twiceRemainder := new(big.Int).Abs(r)
+	twiceRemainder.Lsh(twiceRemainder, 1)
+	if twiceRemainder.Cmp(new(big.Int).Abs(b)) >= 0 {
+		if (a.Sign() < 0) != (b.Sign() < 0) {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+func newNumberFromBig(coefficient *big.Int, scale int32) (Number, error) {
+	c := new(big.Int).Set(coefficient)
+	if scale > decimalPrecision {
+		c = roundedQuotient(c, pow10(scale-decimalPrecision))
+		scale = decimalPrecision
+	}
+	// a coefficient that does not fit keeps fewer digits after the decimal
+	// point, rounded from the exact coefficient so it is only rounded once
+	exact, exactScale := c, scale
+	for scale > 0 && !fitsCoefficient(c) {
+		scale--
+		c = roundedQuotient(exact, pow10(exactScale-scale))
+	}
+	remainder := new(big.Int)
+	for scale > 0 {
+		q, r := new(big.Int).QuoRem(c, bigTen, remainder)
+		if r.Sign() != 0 {
+			break
+		}
+		c = q
+		scale--
+	}
+	if !fitsCoefficient(c) {
+		return Number{}, ErrNumberOverflow{}
+	}
+	return Number{coefficient: c.Int64(), scale: scale}, nil
+}
+
+// fitsCoefficient reports whether c fits the coefficient of a Number. The
+// smallest int64 is excluded so negating a number can not overflow.
+func fitsCoefficient(c *big.Int) bool {
+	return c.IsInt64() && c.Int64() != math.MinInt64
+}
+
+// pow10Int64 holds the powers of ten that fit an int64.
+var pow10Int64 = [...]int64{1, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9, 1e10, 1e11, 1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18}
+
+// mulInt64 returns a × b and false when the product does not fit a
+// coefficient. Neither a nor b may be the smallest int64.
+func mulInt64(a, b int64) (int64, bool) {
+	hi, lo := bits.Mul64(absInt64(a), absInt64(b))
+	if hi != 0 || lo > math.MaxInt64 {
+		return 0, false
+	}
+	if (a < 0) != (b < 0) {
+		return -int64(lo), true
+	}
+	return int64(lo), true
+}
+
+// addInt64 returns a + b and false when the sum does not fit a coefficient.
+func addInt64(a, b int64) (int64, bool) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) || sum == math.MinInt64 {
+		return 0, false
+	}
+	return sum, true
+}
+
+func absInt64(n int64) uint64 {
+	if n < 0 {
+		return uint64(-n)
+	}
+	return uint64(n)
+}
+
+// normalized returns the number removing trailing zeros after the decimal
+// point. The scale must not be more than decimalPrecision.
+func normalized(coefficient int64, scale int32) Number {
+	for scale > 0 && coefficient%10 == 0 {
+		coefficient /= 10
+		scale--
+	}
+	return Number{coefficient: coefficient, scale: scale}
+}
+
+// alignedInt64 returns the coefficients of both numbers at their common
+// scale and false when one of them does not fit an int64.
+func (n Number) alignedInt64(other Number) (int64, int64, int32, bool) {
+	scale := max(n.scale, other.scale)
+	a, aOK := mulInt64(n.coefficient, pow10Int64[scale-n.scale])
+	b, bOK := mulInt64(other.coefficient, pow10Int64[scale-other.scale])
+	return a, b, scale, aOK && bOK
+}
+
+func (n Number) big() *big.Int {
+	return big.NewInt(n.coefficient)
+}
+
+// aligned returns the coefficients of both numbers at their common scale.
+func (n Number) aligned(other Number) (*big.Int, *big.Int, int32) {
+	scale := max(n.scale, other.scale)
+	a := new(big.Int).Mul(n.big(), pow10(scale-n.scale))
+	b := new(big.Int).Mul(other.big(), pow10(scale-other.scale))
+	return a, b, scale
+}
+
+// Add, Sub and Mul calculate with the int64 coefficients and only fall
+// back to big.Int when the result does not fit.
+
+func (n Number) Add(other Number) (Number, error) {
+	if a, b, scale, ok := n.alignedInt64(other); ok {
+		if sum, ok := addInt64(a, b); ok {
+			return normalized(sum, scale), nil
+		}
+	}
+	a, b, scale := n.aligned(other)
+	return newNumberFromBig(a.Add(a, b), scale)
+}
+
+func (n Number) Sub(other Number) (Number, error) {
+	if a, b, scale, ok := n.alignedInt64(other); ok {
+		if difference, ok := addInt64(a, -b); ok {
+			return normalized(difference, scale), nil
+		}
+	}
+	a, b, scale := n.aligned(other)
+	return newNumberFromBig(a.Sub(a, b), scale)
+}
+
+func (n Number) Mul(other Number) (Number, error) {
+	if scale := n.scale + other.scale; scale <= decimalPrecision {
+		if product, ok := mulInt64(n.coefficient, other.coefficient); ok {
+			return normalized(product, scale), nil
+		}
+	}
+	c := new(big.Int).Mul(n.big(), other.big())
+	return newNumberFromBig(c, n.scale+other.scale)
+}
+
+// Pow raises n to the power of the exponent by repeated squaring so large
+// exponents take a few multiplications. The exponent must not be negative.
+func (n Number) Pow(exponent int) (Number, error) {
+	var (
+		result = NewNumber(1)
+		base   = n
+		err    error
+	)
+	for {
+		if exponent&1 == 1 {
+			if result, err = result.Mul(base); err != nil {
+				return Number{}, err
+			}
+		}
+		exponent >>= 1
+		if exponent == 0 {
+			return result, nil
+		}
+		if base, err = base.Mul(base); err != nil {
+			return Number{}, err
+		}
+	}
+}
+
+// Div divides n by other keeping up to decimalPrecision digits after the
+// decimal point.
+func (n Number) Div(other Number) (Number, error) {
+	if other.coefficient == 0 {
+		return Number{}, ErrDivideByZero{}
+	}
+	numerator := new(big.Int).Mul(n.big(), pow10(decimalPrecision+other.scale-n.scale))
+	return newNumberFromBig(roundedQuotient(numerator, other.big()), decimalPrecision)
+}
+
+// Quotient returns the integer portion of n divided by other truncating
+// toward zero.
+func (n Number) Quotient(other Number) (Number, error) {
+	if other.coefficient == 0 {
+		return Number{}, ErrDivideByZero{}
+	}
+	a, b, _ := n.aligned(other)
+	return newNumberFromBig(a.Quo(a, b), 0)
+}
+
+func (n Number) Neg() Number {
+	return Number{coefficient: -n.coefficient, scale: n.scale}
+}
+
+func (n Number) Sign() int {
+	switch {
+	case n.coefficient < 0:
+		return -1
+	case n.coefficient > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (n Number) Cmp(other Number) int {
+	a, b, _ := n.aligned(other)
+	return a.Cmp(b)
+}
+
+func (n Number) IsInteger() bool {
+	return n.scale == 0
+}
+
+// Int returns the number as an int if it is an integer that fits.
+func (n Number) Int() (int, bool) {
+	if !n.IsInteger() || int64(int(n.coefficient)) != n.coefficient {
+		return 0, false
+	}
+	return int(n.coefficient), true
+}
+
+func (n Number) String() string {
+	digits := strconv.FormatInt(n.coefficient, 10)
+	if n.scale == 0 {
+		return digits
+	}
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	if pad := int(n.scale) + 1 - len(digits); pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+	point := len(digits) - int(n.scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
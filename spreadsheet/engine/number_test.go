@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseNumber(t *testing.T) {
+	for _, tt := range []struct {
+		In, Out string
+	}{
+		{In: "0", Out: "0"},
+		{In: "12", Out: "12"},
+		{In: "-3.5", Out: "-3.5"},
+		{In: "0.25", Out: "0.25"},
+		{In: "1.50", Out: "1.5"},
+		{In: "2.000", Out: "2"},
+		{In: ".5", Out: "0.5"},
+		{In: "7.", Out: "7"},
+		{In: "-0.05", Out: "-0.05"},
+		{In: "0.00000000001", Out: "0"},
+		{In: "0.00000000005", Out: "0.0000000001"},
+	} {
+		t.Run(tt.In, func(t *testing.T) {
+			n, err := ParseNumber(tt.In)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := n.String(); got != tt.Out {
+				t.Errorf("expected %s got %s", tt.Out, got)
+			}
+		})
+	}
+
+	for _, in := range []string{"", ".", "1.2.3", "1e5", "abc", "99999999999999999999"} {
+		t.Run(in, func(t *testing.T) {
+			if _, err := ParseNumber(in); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestNumber_arithmetic(t *testing.T) {
+	for _, tt := range []struct {
+		Name        string
+		Left, Right string
+		Op          func(Number, Number) (Number, error)
+		Result      string
+	}{
+		{Name: "add without drift", Left: "0.1", Right: "0.2", Op: Number.Add, Result: "0.3"},
+		{Name: "add integers", Left: "40", Right: "2", Op: Number.Add, Result: "42"},
+		{Name: "add different scales", Left: "1.5", Right: "0.25", Op: Number.Add, Result: "1.75"},
+		{Name: "add to zero", Left: "0.5", Right: "-0.5", Op: Number.Add, Result: "0"},
+		{Name: "add past int64", Left: "9223372036854775807", Right: "-0.5", Op: Number.Add, Result: "9223372036854775807"},
+		{Name: "subtract", Left: "1", Right: "0.75", Op: Number.Sub, Result: "0.25"},
+		{Name: "subtract trims zeros", Left: "1.25", Right: "0.05", Op: Number.Sub, Result: "1.2"},
+		{Name: "multiply negative", Left: "-1.5", Right: "2", Op: Number.Mul, Result: "-3"},
+		{Name: "multiply", Left: "1.5", Right: "1.5", Op: Number.Mul, Result: "2.25"},
+		{Name: "multiply rounds to precision", Left: "0.00001", Right: "0.000005", Op: Number.Mul, Result: "0.0000000001"},
+		{Name: "divide exactly", Left: "7", Right: "2", Op: Number.Div, Result: "3.5"},
+		{Name: "divide repeating", Left: "1", Right: "3", Op: Number.Div, Result: "0.3333333333"},
+		{Name: "divide rounds half away from zero", Left: "2", Right: "3", Op: Number.Div, Result: "0.6666666667"},
+		{Name: "divide negative", Left: "-2", Right: "3", Op: Number.Div, Result: "-0.6666666667"},
+		{Name: "divide large keeps fewer digits", Left: "10000000000", Right: "3", Op: Number.Div, Result: "3333333333.333333333"},
+		{Name: "quotient", Left: "7", Right: "2", Op: Number.Quotient, Result: "3"},
+		{Name: "quotient truncates toward zero", Left: "-7", Right: "2", Op: Number.Quotient, Result: "-3"},
+		{Name: "quotient of decimals", Left: "7.5", Right: "2.5", Op: Number.Quotient, Result: "3"},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			left, err := ParseNumber(tt.Left)
+			if err != nil {
+				t.Fatal(err)
+			}
+			right, err := ParseNumber(tt.Right)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := tt.Op(left, right)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := result.String(); got != tt.Result {
+				t.Errorf("expected %s got %s", tt.Result, got)
+			}
+		})
+	}
+}
+
+func TestNumber_errors(t *testing.T) {
+	if _, err := NewNumber(1).Div(Number{}); err == nil {
+		t.Errorf("expected divide by zero error")
+	}
+	if _, err := NewNumber(1).Quotient(Number{}); err == nil {
+		t.Errorf("expected divide by zero error")
+	}
+	large := Number{coefficient: 1 << 62}
+	if _, err := large.Add(large); !errors.Is(err, ErrNumberOverflow{}) {
+		t.Errorf("expected overflow error got %v", err)
+	}
+	if _, err := large.Mul(NewNumber(4)); !errors.Is(err, ErrNumberOverflow{}) {
+		t.Errorf("expected overflow error got %v", err)
+	}
+}
+
+func TestNumber_Cmp(t *testing.T) {
+	a, _ := ParseNumber("1.25")
+	b, _ := ParseNumber("1.3")
+	if a.Cmp(b) >= 0 || b.Cmp(a) <= 0 || a.Cmp(a) != 0 {
+		t.Errorf("unexpected comparison results")
+	}
+}
+
+func Test_decimalExpressions(t *testing.T) {
+	for _, tt := range []struct {
+		Expression string
+		Result     string
+	}{
+		{Expression: "0.1 + 0.2", Result: "0.3"},
+		{Expression: "7 / 2", Result: "3.5"},
+		{Expression: "QUOTIENT(7, 2)", Result: "3"},
+		{Expression: "1.5 ^ 2", Result: "2.25"},
+		{Expression: "AVG(1, 2)", Result: "1.5"},
+		{Expression: "AVG(10000000000, 10000000001, 10000000001)", Result: "10000000000.66666667"},
+		{Expression: "MIN(2.5, 1.25, 3)", Result: "1.25"},
+		{Expression: "3!", Result: "6"},
+	} {
+		t.Run(tt.Expression, func(t *testing.T) {
+			exp, _, err := newExpression(tt.Expression, 9, 9)
+			if err != nil {
+				t.Fatal(err)
+			}
+			table := NewTable(10, 10)
+			value, err := evaluate(&table, &Cell{}, newWalkState(0), exp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := value.String(); got != tt.Result {
+				t.Errorf("expected %s got %s", tt.Result, got)
+			}
+		})
+	}
+
+	for _, expression := range []string{"1.5!", "2 ^ 0.5", "QUOTIENT(1)", "QUOTIENT(1, 0)"} {
+		t.Run(expression, func(t *testing.T) {
+			exp, _, err := newExpression(expression, 9, 9)
+			if err != nil {
+				return
+			}
+			table := NewTable(10, 10)
+			if _, err := evaluate(&table, &Cell{}, newWalkState(0), exp); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,380 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+)
+
+// ParseError describes a problem with an expression and where in the
+// expression text it was found.
+type ParseError struct {
+	Offset  int
+	Message string
+}
+
+func (err ParseError) Error() string {
+	return fmt.Sprintf("%s at expression offset %d", err.Message, err.Offset)
+}
+
+func parseErrorf(offset int, format string, a ...any) ParseError {
+	return ParseError{Offset: offset, Message: fmt.Sprintf(format, a...)}
+}
+
+const (
+	precedenceComparison = iota + 1
+	precedenceConcatenate
+	precedenceAdditive
+	precedenceMultiplicative
+	precedenceUnary
+	precedenceExponent
+)
+
+// binaryPrecedence returns the binding power of a binary operator and
+// whether it is right associative. Tokens that are not binary operators have
+// a precedence of zero.
+func binaryPrecedence(tokenType TokenType) (int, bool) {
+	switch tokenType {
+	case TokenEqual, TokenNotEqual, TokenLess, TokenLessOrEqual, TokenGreater, TokenGreaterOrEqual:
+		return precedenceComparison, false
+	case TokenConcatenate:
+		return precedenceConcatenate, false
+	case TokenAdd, TokenSubtract:
+		return precedenceAdditive, false
+	case TokenMultiply, TokenDivide:
+		return precedenceMultiplicative, false
+	case TokenExponent:
+		return precedenceExponent, true
+	default:
+		return 0, false
+	}
+}
+
+type parser struct {
+	tokens     []Token
+	i          int
+	end        int
+	references []CellIdentifier
+
+	maxColumn, maxRow int
+
+	// depth is the number of parentheses and function calls the parser is
+	// inside of.
+	depth int
+}
+
+// parse parses the tokens starting at index i into an expression tree using
+// precedence climbing. All the remaining tokens must be part of the
+// expression. It returns the cells referenced by the expression and the
+// index after the last token consumed.
+func parse(tokens []Token, i, maxColumn, maxRow int) (ExpressionNode, []CellIdentifier, int, error) {
+	p := &parser{
+		tokens:    tokens,
+		i:         i,
+		maxColumn: maxColumn,
+		maxRow:    maxRow,
+	}
+	if len(tokens) > 0 {
+		last := tokens[len(tokens)-1]
+		p.end = last.Index + len(last.Value)
+	}
+	if p.i >= len(p.tokens) {
+		return nil, nil, p.i, parseErrorf(p.end, "expected an expression")
+	}
+	node, err := p.parseExpression(precedenceComparison)
+	if err != nil {
+		return nil, nil, p.i, err
+	}
+	if p.i < len(p.tokens) {
+		token := p.tokens[p.i]
+		return nil, nil, p.i, parseErrorf(token.Index, "unexpected %q", token.Value)
+	}
+	return node, p.references, p.i, nil
+}
+
+func (p *parser) peek() (Token, bool) {
+	if p.i >= len(p.tokens) {
+		return Token{}, false
+	}
+	return p.tokens[p.i], true
+}
+
+// offset returns where the next token starts or the end of the expression.
+func (p *parser) offset() int {
+	if token, ok := p.peek(); ok {
+		return token.Index
+	}
+	return p.end
+}
+
+// nest enters a parenthesis or function call starting at token. It fails
+// when the formula is nested deeper than the limit.
+func (p *parser) nest(token Token) error {
+	p.depth++
+	if p.depth > limits.NestingDepth {
+		return parseErrorf(token.Index, "the formula is nested deeper than %d parentheses and function calls", limits.NestingDepth)
+	}
+	return nil
+}
+
+func (p *parser) expect(tokenType TokenType, description string) (Token, error) {
+	token, ok := p.peek()
+	if !ok {
+		return Token{}, parseErrorf(p.end, "expected %s but the expression ended", description)
+	}
+	if token.Type != tokenType {
+		return Token{}, parseErrorf(token.Index, "expected %s but got %q", description, token.Value)
+	}
+	p.i++
+	return token, nil
+}
+
+// parseExpression parses binary operators that bind at least as tightly as
+// minPrecedence.
+func (p *parser) parseExpression(minPrecedence int) (ExpressionNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.peek()
+		if !ok {
+			return left, nil
+		}
+		precedence, rightAssociative := binaryPrecedence(op.Type)
+		if precedence == 0 || precedence < minPrecedence {
+			return left, nil
+		}
+		p.i++
+		next := precedence + 1
+		if rightAssociative {
+			next = precedence
+		}
+		right, err := p.parseExpression(next)
+		if err != nil {
+			return nil, err
+		}
+		left = BinaryExpressionNode{Op: op, Left: left, Right: right}
+	}
+}
+
+// parseUnary parses prefix plus and minus. They bind tighter than
+// multiplication but looser than exponentiation so -2^2 is -(2^2).
+func (p *parser) parseUnary() (ExpressionNode, error) {
+	token, ok := p.peek()
+	if ok && (token.Type == TokenSubtract || token.Type == TokenAdd) {
+		p.i++
+		operand, err := p.parseExpression(precedenceUnary)
+		if err != nil {
+			return nil, err
+		}
+		return UnaryExpressionNode{Op: token, Expression: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+// parsePostfix parses a primary expression followed by any number of
+// factorial operators.
+func (p *parser) parsePostfix() (ExpressionNode, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		token, ok := p.peek()
+		if !ok || token.Type != TokenExclamation {
+			return node, nil
+		}
+		p.i++
+		node = FactorialNode{Expression: node}
+	}
+}
+
+func (p *parser) parsePrimary() (ExpressionNode, error) {
+	token, ok := p.peek()
+	if !ok {
+		return nil, parseErrorf(p.end, "expected a value but the expression ended")
+	}
+	switch token.Type {
+	case TokenNumber:
+		p.i++
+		n, err := ParseNumber(token.Value)
+		if err != nil {
+			return nil, parseErrorf(token.Index, "failed to parse number %s: %s", token.Value, err)
+		}
+		return NumberNode{Token: token, Value: n}, nil
+	case TokenString:
+		p.i++
+		return TextNode{Token: token}, nil
+	case TokenError:
+		p.i++
+		return ErrorNode{Token: token}, nil
+	case TokenLeftParenthesis:
+		p.i++
+		if err := p.nest(token); err != nil {
+			return nil, err
+		}
+		defer func() { p.depth-- }()
+		node, err := p.parseExpression(precedenceComparison)
+		if err != nil {
+			return nil, err
+		}
+		end, err := p.expect(TokenRightParenthesis, "a closing parenthesis")
+		if err != nil {
+			if _, ok := p.peek(); !ok {
+				return nil, parseErrorf(token.Index, "parenthesis is missing closing parenthesis")
+			}
+			return nil, err
+		}
+		return ParenNode{Start: token, End: end, Node: node}, nil
+	case TokenIdentifier:
+		return p.parseIdentifier()
+	default:
+		return nil, parseErrorf(token.Index, "expected a value but got %q", token.Value)
+	}
+}
+
+// parseIdentifier parses variables, booleans, function calls, cell
+// references and ranges.
+func (p *parser) parseIdentifier() (ExpressionNode, error) {
+	token := p.tokens[p.i]
+	p.i++
+	if p.sheetReference(token) {
+		return p.parseSheetReference(token)
+	}
+	switch token.Value {
+	case RowIdent, ColumnIdent, MaxRowIdent, MaxColumnIdent, MinRowIdent, MinColumnIdent:
+		return VariableNode{Identifier: token}, nil
+	case TrueIdent, FalseIdent:
+		return BooleanNode{Token: token, Value: token.Value == TrueIdent}, nil
+	}
+	if next, ok := p.peek(); ok && next.Type == TokenLeftParenthesis {
+		return p.parseFunction(token)
+	}
+	if isName(token.Value) {
+		return NameNode{Token: token}, nil
+	}
+	from, err := p.cellReference(token)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseRange(from, p.maxColumn, p.maxRow)
+}
+
+// sheetReference reports whether the sheet name token is followed by an
+// exclamation mark and a cell without any space in between, like SHEET2!B3.
+// With a space the exclamation mark is a factorial.
+func (p *parser) sheetReference(sheet Token) bool {
+	if p.i+1 >= len(p.tokens) {
+		return false
+	}
+	exclamation, cell := p.tokens[p.i], p.tokens[p.i+1]
+	return exclamation.Type == TokenExclamation && exclamation.Index == sheet.Index+len(sheet.Value) &&
+		cell.Type == TokenIdentifier && cell.Index == exclamation.Index+1
+}
+
+// parseSheetReference parses a reference to a cell or range of another
+// sheet. The size of the other sheet is not known while parsing so the
+// reference is checked when it is evaluated.
+func (p *parser) parseSheetReference(sheet Token) (ExpressionNode, error) {
+	token := p.tokens[p.i+1]
+	p.i += 2
+	from, err := p.cellReferenceIn(token, math.MaxInt, math.MaxInt)
+	if err != nil {
+		return nil, err
+	}
+	from.Sheet = sheet.Value
+	return p.parseRange(from, math.MaxInt, math.MaxInt)
+}
+
+// parseRange parses the end of a range when from is followed by a colon.
+// Otherwise, from is a single cell reference.
+func (p *parser) parseRange(from IdentifierNode, maxColumn, maxRow int) (ExpressionNode, error) {
+	if next, ok := p.peek(); !ok || next.Type != TokenColon {
+		p.references = append(p.references, CellIdentifier{sheet: from.Sheet, row: from.Row, column: from.Column})
+		return from, nil
+	}
+	p.i++
+	toToken, err := p.expect(TokenIdentifier, "a cell identifier after the colon")
+	if err != nil {
+		return nil, err
+	}
+	to, err := p.cellReferenceIn(toToken, maxColumn, maxRow)
+	if err != nil {
+		return nil, err
+	}
+	to.Sheet = from.Sheet
+	// ranges are not added to the references, the dependency graph keeps
+	// them as a single edge
+	return RangeNode{From: from, To: to}, nil
+}
+
+func (p *parser) cellReference(token Token) (IdentifierNode, error) {
+	return p.cellReferenceIn(token, p.maxColumn, p.maxRow)
+}
+
+func (p *parser) cellReferenceIn(token Token, maxColumn, maxRow int) (IdentifierNode, error) {
+	label := token.Value
+	parts := referencePattern.FindStringSubmatch(label)
+	if parts != nil {
+		label = parts[referencePattern.SubexpIndex("column")] + parts[referencePattern.SubexpIndex("row")]
+	}
+	column, row, err := ParseCellID(label, maxColumn, maxRow)
+	if err != nil {
+		return IdentifierNode{}, parseErrorf(token.Index, "%s: %s", token.Value, err)
+	}
+	return IdentifierNode{
+		Token:          token,
+		Row:            row,
+		Column:         column,
+		AbsoluteColumn: parts[referencePattern.SubexpIndex("absoluteColumn")] != "",
+		AbsoluteRow:    parts[referencePattern.SubexpIndex("absoluteRow")] != "",
+	}, nil
+}
+
+// parseFunction parses the parenthesized, comma separated arguments of a
+// call to the function named by name.
+func (p *parser) parseFunction(name Token) (ExpressionNode, error) {
+	fn, ok := functions[name.Value]
+	if !ok {
+		return nil, parseErrorf(name.Index, "unknown function %s", name.Value)
+	}
+	open := p.tokens[p.i]
+	p.i++
+	if err := p.nest(name); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	node := FunctionNode{Name: name}
+	if next, ok := p.peek(); ok && next.Type == TokenRightParenthesis {
+		p.i++
+	} else {
+		for {
+			if next, ok := p.peek(); ok && (next.Type == TokenComma || next.Type == TokenRightParenthesis) {
+				return nil, parseErrorf(next.Index, "function %s has an empty argument", name.Value)
+			}
+			argument, err := p.parseExpression(precedenceComparison)
+			if err != nil {
+				return nil, err
+			}
+			node.Arguments = append(node.Arguments, argument)
+			next, ok := p.peek()
+			if !ok {
+				return nil, parseErrorf(open.Index, "function %s is missing closing parenthesis", name.Value)
+			}
+			p.i++
+			if next.Type == TokenRightParenthesis {
+				break
+			}
+			if next.Type != TokenComma {
+				return nil, parseErrorf(next.Index, "expected a comma or closing parenthesis but got %q", next.Value)
+			}
+		}
+	}
+	if len(node.Arguments) < fn.minArguments {
+		return nil, parseErrorf(name.Index, "function %s expects at least %d arguments but got %d", name.Value, fn.minArguments, len(node.Arguments))
+	}
+	if fn.maxArguments > 0 && len(node.Arguments) > fn.maxArguments {
+		return nil, parseErrorf(name.Index, "function %s expects at most %d arguments but got %d", name.Value, fn.maxArguments, len(node.Arguments))
+	}
+	return node, nil
+}
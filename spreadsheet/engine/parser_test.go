@@ -0,0 +1,261 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// sexpr formats an expression tree so the structure chosen by the parser is
+// explicit, for example 1 + 2 * 3 is (+ 1 (* 2 3)).
+func sexpr(node ExpressionNode) string {
+	switch n := node.(type) {
+	case BinaryExpressionNode:
+		return fmt.Sprintf("(%s %s %s)", n.Op.Value, sexpr(n.Left), sexpr(n.Right))
+	case UnaryExpressionNode:
+		return fmt.Sprintf("(%s %s)", n.Op.Value, sexpr(n.Expression))
+	case FactorialNode:
+		return fmt.Sprintf("(! %s)", sexpr(n.Expression))
+	case ParenNode:
+		return sexpr(n.Node)
+	case FunctionNode:
+		parts := []string{n.Name.Value}
+		for _, argument := range n.Arguments {
+			parts = append(parts, sexpr(argument))
+		}
+		return "(" + strings.Join(parts, " ") + ")"
+	default:
+		return node.String()
+	}
+}
+
+func Test_parse_structure(t *testing.T) {
+	for _, tt := range []struct {
+		Expression string
+		Tree       string
+	}{
+		{Expression: "1", Tree: "1"},
+		{Expression: "1 + 2", Tree: "(+ 1 2)"},
+		{Expression: "1 + 2 + 3", Tree: "(+ (+ 1 2) 3)"},
+		{Expression: "1 - 2 - 3", Tree: "(- (- 1 2) 3)"},
+		{Expression: "1 - 2 + 3", Tree: "(+ (- 1 2) 3)"},
+		{Expression: "1 + 2 - 3", Tree: "(- (+ 1 2) 3)"},
+		{Expression: "8 / 4 / 2", Tree: "(/ (/ 8 4) 2)"},
+		{Expression: "8 / 4 * 2", Tree: "(* (/ 8 4) 2)"},
+		{Expression: "8 * 4 / 2", Tree: "(/ (* 8 4) 2)"},
+		{Expression: "1 + 2 * 3", Tree: "(+ 1 (* 2 3))"},
+		{Expression: "1 * 2 + 3", Tree: "(+ (* 1 2) 3)"},
+		{Expression: "1 + 2 * 3 + 4", Tree: "(+ (+ 1 (* 2 3)) 4)"},
+		{Expression: "1 * 2 + 3 * 4", Tree: "(+ (* 1 2) (* 3 4))"},
+		{Expression: "1 + 2 * 3 ^ 4", Tree: "(+ 1 (* 2 (^ 3 4)))"},
+		{Expression: "2 ^ 3 ^ 2", Tree: "(^ 2 (^ 3 2))"},
+		{Expression: "2 ^ 3 * 4", Tree: "(* (^ 2 3) 4)"},
+		{Expression: "(1 + 2) * 3", Tree: "(* (+ 1 2) 3)"},
+		{Expression: "((1))", Tree: "1"},
+		{Expression: "-1", Tree: "(- 1)"},
+		{Expression: "+1", Tree: "(+ 1)"},
+		{Expression: "--1", Tree: "(- (- 1))"},
+		{Expression: "-2 ^ 2", Tree: "(- (^ 2 2))"},
+		{Expression: "2 ^ -1", Tree: "(^ 2 (- 1))"},
+		{Expression: "-2 * 3", Tree: "(* (- 2) 3)"},
+		{Expression: "2 * -3", Tree: "(* 2 (- 3))"},
+		{Expression: "1 - -1", Tree: "(- 1 (- 1))"},
+		{Expression: "3!", Tree: "(! 3)"},
+		{Expression: "3!!", Tree: "(! (! 3))"},
+		{Expression: "1 - 3!", Tree: "(- 1 (! 3))"},
+		{Expression: "-3!", Tree: "(- (! 3))"},
+		{Expression: "2 ^ 3!", Tree: "(^ 2 (! 3))"},
+		{Expression: "(1 + 2)!", Tree: "(! (+ 1 2))"},
+		{Expression: "1 < 2 + 3", Tree: "(< 1 (+ 2 3))"},
+		{Expression: "1 + 2 = 3", Tree: "(= (+ 1 2) 3)"},
+		{Expression: "1 = 2 = FALSE", Tree: "(= (= 1 2) FALSE)"},
+		{Expression: `"a" & 1 + 2`, Tree: `(& "a" (+ 1 2))`},
+		{Expression: `"a" & "b" = "ab"`, Tree: `(= (& "a" "b") "ab")`},
+		{Expression: "A0 + B1 * C2", Tree: "(+ A0 (* B1 C2))"},
+		{Expression: "SUM(A0:A3) * 2", Tree: "(* (SUM A0:A3) 2)"},
+		{Expression: "SUM(1 + 2, 3 * 4)", Tree: "(SUM (+ 1 2) (* 3 4))"},
+		{Expression: "-SUM(1)", Tree: "(- (SUM 1))"},
+		{Expression: "IF(A0 > 1, -1, 2 ^ 2)", Tree: "(IF (> A0 1) (- 1) (^ 2 2))"},
+		{Expression: "MAX(MIN(1, 2), 3)", Tree: "(MAX (MIN 1 2) 3)"},
+		{Expression: "ROW + COLUMN", Tree: "(+ ROW COLUMN)"},
+	} {
+		t.Run(tt.Expression, func(t *testing.T) {
+			exp, _, err := newExpression(tt.Expression, 9, 9)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sexpr(exp); got != tt.Tree {
+				t.Errorf("expected %s got %s", tt.Tree, got)
+			}
+			reparsed, _, err := newExpression(exp.String(), 9, 9)
+			if err != nil {
+				t.Fatalf("failed to parse formatted expression %q: %s", exp.String(), err)
+			}
+			if got := sexpr(reparsed); got != tt.Tree {
+				t.Errorf("formatted expression %q parsed as %s", exp.String(), got)
+			}
+		})
+	}
+}
+
+func Test_parse_evaluate(t *testing.T) {
+	for _, tt := range []struct {
+		Expression string
+		Result     string
+	}{
+		{Expression: "10 - 4 - 3", Result: "3"},
+		{Expression: "10 - 4 + 3", Result: "9"},
+		{Expression: "100 / 10 / 5", Result: "2"},
+		{Expression: "100 / 10 * 5", Result: "50"},
+		{Expression: "2 ^ 3 ^ 2", Result: "512"},
+		{Expression: "(2 ^ 3) ^ 2", Result: "64"},
+		{Expression: "-2 ^ 2", Result: "-4"},
+		{Expression: "(-2) ^ 2", Result: "4"},
+		{Expression: "-3", Result: "-3"},
+		{Expression: "- 3 + 5", Result: "2"},
+		{Expression: "+3", Result: "3"},
+		{Expression: "--3", Result: "3"},
+		{Expression: "1 - -3", Result: "4"},
+		{Expression: "2 * -3", Result: "-6"},
+		{Expression: "-3!", Result: "-6"},
+		{Expression: "3!!", Result: "720"},
+		{Expression: "2 + 3 * 4 - 5", Result: "9"},
+		{Expression: "2 * (3 + 4) * 5", Result: "70"},
+		{Expression: "1 + 2 * 3 ^ 2", Result: "19"},
+		{Expression: "-0.5 * 4", Result: "-2"},
+		{Expression: "1 + 2 < 4", Result: "TRUE"},
+		{Expression: "1 + 2 >= 4", Result: "FALSE"},
+		{Expression: `"n=" & 1 + 2`, Result: "n=3"},
+		{Expression: "-SUM(1, 2)", Result: "-3"},
+		{Expression: "SUM(-1, -2)", Result: "-3"},
+		{Expression: "IF(1 > 2, 1, -1)", Result: "-1"},
+		{Expression: "ROW * 10 + COLUMN", Result: "32"},
+		{Expression: "MAX_ROW - MIN_ROW", Result: "9"},
+	} {
+		t.Run(tt.Expression, func(t *testing.T) {
+			exp, _, err := newExpression(tt.Expression, 9, 9)
+			if err != nil {
+				t.Fatal(err)
+			}
+			table := NewTable(10, 10)
+			value, err := evaluate(&table, &Cell{Column: 2, Row: 3}, newWalkState(0), exp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := value.String(); got != tt.Result {
+				t.Errorf("expected %s got %s", tt.Result, got)
+			}
+		})
+	}
+}
+
+func Test_parse_errors(t *testing.T) {
+	for _, tt := range []struct {
+		Expression string
+		Offset     int
+		Message    string
+	}{
+		{Expression: "", Offset: 0, Message: "expected an expression"},
+		{Expression: "1 +", Offset: 3, Message: "expected a value"},
+		{Expression: "* 2", Offset: 0, Message: "expected a value"},
+		{Expression: "1 2", Offset: 2, Message: `unexpected "2"`},
+		{Expression: "(1 + 2", Offset: 0, Message: "missing closing parenthesis"},
+		{Expression: "1 + 2)", Offset: 5, Message: `unexpected ")"`},
+		{Expression: "()", Offset: 1, Message: "expected a value"},
+		{Expression: "1 $ 2", Offset: 2, Message: "unexpected character '$'"},
+		{Expression: "1 # 2", Offset: 2, Message: "unexpected character '#'"},
+		{Expression: "A1 ; 2", Offset: 3, Message: "unexpected character ';'"},
+		{Expression: "1 + €", Offset: 4, Message: "unexpected character '€'"},
+		{Expression: "1.2.3", Offset: 3, Message: "unexpected character '.'"},
+		{Expression: `"abc`, Offset: 0, Message: "missing a closing quote"},
+		{Expression: "1 + Z99", Offset: 4, Message: "Z99"},
+		{Expression: "1 + A1B", Offset: 4, Message: "A1B"},
+		{Expression: "NOPE(1)", Offset: 0, Message: "unknown function NOPE"},
+		{Expression: "SUM()", Offset: 0, Message: "expects at least 1 arguments"},
+		{Expression: "NOT(1, 2)", Offset: 0, Message: "expects at most 1 arguments"},
+		{Expression: "SUM(1,,2)", Offset: 6, Message: "empty argument"},
+		{Expression: "SUM(1, 2", Offset: 3, Message: "missing closing parenthesis"},
+		{Expression: "SUM(1 2)", Offset: 6, Message: "expected a comma or closing parenthesis"},
+		{Expression: "A0:", Offset: 3, Message: "expected a cell identifier after the colon"},
+		{Expression: "A0:1", Offset: 3, Message: "expected a cell identifier after the colon"},
+		{Expression: "1, 2", Offset: 1, Message: `unexpected ","`},
+		{Expression: "!", Offset: 0, Message: "expected a value"},
+	} {
+		t.Run(tt.Expression, func(t *testing.T) {
+			_, _, err := newExpression(tt.Expression, 9, 9)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			var parseError ParseError
+			if !errors.As(err, &parseError) {
+				t.Fatalf("expected a ParseError got %T: %s", err, err)
+			}
+			if parseError.Offset != tt.Offset {
+				t.Errorf("expected offset %d got %d (%s)", tt.Offset, parseError.Offset, err)
+			}
+			if !strings.Contains(err.Error(), tt.Message) {
+				t.Errorf("expected error to contain %q got %q", tt.Message, err)
+			}
+		})
+	}
+}
+
+func Test_parse_references(t *testing.T) {
+	for _, tt := range []struct {
+		Expression string
+		References []string
+	}{
+		{Expression: "1 + 2", References: nil},
+		{Expression: "A0 + B1", References: []string{"A0", "B1"}},
+		{Expression: "(A0 + B1) * C2", References: []string{"A0", "B1", "C2"}},
+		{Expression: "-A0 ^ B0!", References: []string{"A0", "B0"}},
+		{Expression: "SUM(A0:B1, C0)", References: []string{"C0"}},
+		{Expression: "IF(A0, B0, C0)", References: []string{"A0", "B0", "C0"}},
+	} {
+		t.Run(tt.Expression, func(t *testing.T) {
+			_, refs, err := newExpression(tt.Expression, 9, 9)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, ref := range refs {
+				got = append(got, fmt.Sprintf("%s%d", ColumnLabel(ref.column), ref.row))
+			}
+			if strings.Join(got, " ") != strings.Join(tt.References, " ") {
+				t.Errorf("expected %v got %v", tt.References, got)
+			}
+		})
+	}
+}
+
+func Test_tokenize(t *testing.T) {
+	for _, tt := range []struct {
+		Input  string
+		Tokens string
+	}{
+		{Input: "1+2", Tokens: "1 + 2"},
+		{Input: " 12.5 ", Tokens: "12.5"},
+		{Input: "A0<>B0", Tokens: "A0 <> B0"},
+		{Input: "1<=2>=3<4>5=6", Tokens: "1 <= 2 >= 3 < 4 > 5 = 6"},
+		{Input: "SUM(A0:B1,2)", Tokens: "SUM ( A0 : B1 , 2 )"},
+		{Input: `"a b"&C1`, Tokens: "a b & C1"},
+		{Input: "MAX_ROW-1", Tokens: "MAX_ROW - 1"},
+		{Input: "3!^2", Tokens: "3 ! ^ 2"},
+		{Input: "\t1\n+\r2", Tokens: "1 + 2"},
+	} {
+		t.Run(tt.Input, func(t *testing.T) {
+			tokens, err := tokenize(tt.Input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var values []string
+			for _, token := range tokens {
+				values = append(values, token.Value)
+			}
+			if got := strings.Join(values, " "); got != tt.Tokens {
+				t.Errorf("expected %q got %q", tt.Tokens, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"iter"
+	"slices"
+)
+
+// rangeBlockSize is the number of columns and rows of the blocks of cells
+// a rangeIndex groups ranges by.
+const rangeBlockSize = 16
+
+// rangeIndex holds the ranges referenced by cells. Each range is added to
+// the blocks of cells it overlaps so the ranges covering a cell are found
+// without going through every range of the sheet.
+type rangeIndex struct {
+	// ranges maps each cell to the ranges its expression references.
+	ranges map[CellIdentifier][]RangeNode
+
+	// blocks maps a block of a sheet to the ranges overlapping it.
+	blocks map[rangeBlock][]rangeEdge
+}
+
+// rangeBlock identifies a block of rangeBlockSize by rangeBlockSize cells
+// of a sheet.
+type rangeBlock struct {
+	sheet       string
+	column, row int
+}
+
+// rangeEdge is a range referenced by the dependent cell.
+type rangeEdge struct {
+	dependent                            CellIdentifier
+	minColumn, minRow, maxColumn, maxRow int
+}
+
+// eachBlock calls fn with every block the range overlaps. The range must be
+// inside its sheet.
+func eachBlock(r RangeNode, fn func(rangeBlock)) {
+	minColumn, minRow, maxColumn, maxRow := r.Bounds()
+	for row := minRow / rangeBlockSize; row <= maxRow/rangeBlockSize; row++ {
+		for column := minColumn / rangeBlockSize; column <= maxColumn/rangeBlockSize; column++ {
+			fn(rangeBlock{sheet: r.From.Sheet, column: column, row: row})
+		}
+	}
+}
+
+// set replaces the ranges referenced by the cell identified by id.
+func (index *rangeIndex) set(id CellIdentifier, ranges []RangeNode) {
+	for _, r := range index.ranges[id] {
+		eachBlock(r, func(b rangeBlock) {
+			edges := slices.DeleteFunc(index.blocks[b], func(edge rangeEdge) bool { return edge.dependent == id })
+			if len(edges) == 0 {
+				delete(index.blocks, b)
+			} else {
+				index.blocks[b] = edges
+			}
+		})
+	}
+	delete(index.ranges, id)
+	if len(ranges) == 0 {
+		return
+	}
+	if index.ranges == nil {
+		index.ranges = make(map[CellIdentifier][]RangeNode)
+		index.blocks = make(map[rangeBlock][]rangeEdge)
+	}
+	index.ranges[id] = ranges
+	for _, r := range ranges {
+		edge := rangeEdge{dependent: id}
+		edge.minColumn, edge.minRow, edge.maxColumn, edge.maxRow = r.Bounds()
+		eachBlock(r, func(b rangeBlock) {
+			index.blocks[b] = append(index.blocks[b], edge)
+		})
+	}
+}
+
+// covering iterates over the cells referencing a range that covers the cell
+// identified by id. A cell referencing several such ranges is yielded once
+// for each of them.
+func (index *rangeIndex) covering(id CellIdentifier) iter.Seq[CellIdentifier] {
+	return func(yield func(CellIdentifier) bool) {
+		block := rangeBlock{sheet: id.sheet, column: id.column / rangeBlockSize, row: id.row / rangeBlockSize}
+		for _, edge := range index.blocks[block] {
+			covers := id.column >= edge.minColumn && id.column <= edge.maxColumn && id.row >= edge.minRow && id.row <= edge.maxRow
+			if covers && !yield(edge.dependent) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Function is the Go implementation of a function registered with
+// RegisterFunction. It is called with the evaluated arguments, a range
+// argument is a RangeValue. A returned error is shown on the cell like the
+// errors of the built-in functions: return an ErrorValue to choose its kind,
+// any other error is a #VALUE! error.
+type Function func(arguments []Value) (Value, error)
+
+// RegisterFunction makes fn callable from formulas as name. Names are
+// written in upper case in formulas and must start with a letter or
+// underscore, only contain letters, digits and underscores and not start
+// like a cell reference, so AB12 or LOG10 can not be function names.
+// Formulas must pass at least minArguments and, unless maxArguments is
+// zero, at most maxArguments arguments.
+//
+// Register functions before loading workbooks that use them, for example in
+// main. RegisterFunction must not be called while formulas are parsed or
+// calculated.
+func RegisterFunction(name string, minArguments, maxArguments int, fn Function) error {
+	name = strings.ToUpper(name)
+	if err := checkFunctionName(name); err != nil {
+		return err
+	}
+	switch {
+	case fn == nil:
+		return fmt.Errorf("function %s has no implementation", name)
+	case minArguments < 0 || maxArguments < 0:
+		return fmt.Errorf("function %s can not expect a negative number of arguments", name)
+	case maxArguments > 0 && maxArguments < minArguments:
+		return fmt.Errorf("function %s expects at least %d arguments so it can not expect at most %d", name, minArguments, maxArguments)
+	}
+	functions[name] = function{minArguments: minArguments, maxArguments: maxArguments, call: registeredCall(name, fn)}
+	return nil
+}
+
+func checkFunctionName(name string) error {
+	switch {
+	case !isName(name):
+		return fmt.Errorf("function name %q must start with a letter or underscore, only contain letters, digits and underscores and not start like a cell reference", name)
+	case slices.Contains(variableIdents, name):
+		return fmt.Errorf("function name %s is a variable", name)
+	}
+	if _, ok := functions[name]; ok {
+		return fmt.Errorf("function %s already exists", name)
+	}
+	return nil
+}
+
+// registeredCall calls a registered function. A panic is reported as an
+// error on the cell so a broken function does not stop the recalculation.
+func registeredCall(name string, fn Function) func([]Value) (Value, error) {
+	return func(arguments []Value) (result Value, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				result, err = nil, newErrorValue(ErrorValueType, "function %s failed: %v", name, r)
+			}
+		}()
+		result, err = fn(arguments)
+		if err != nil {
+			var errorValue ErrorValue
+			if !errors.As(err, &errorValue) {
+				err = newErrorValue(ErrorValueType, "function %s failed: %s", name, err)
+			}
+			return nil, err
+		}
+		if r, ok := result.(RangeValue); ok {
+			return nil, TypeError{Expected: "function " + name + " to return a single value", Got: r}
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func registerTestFunction(t *testing.T, name string, minArguments, maxArguments int, fn Function) {
+	t.Helper()
+	if err := RegisterFunction(name, minArguments, maxArguments, fn); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { delete(functions, strings.ToUpper(name)) })
+}
+
+func TestRegisterFunction(t *testing.T) {
+	rates := map[Text]Number{"EUR": NewNumber(2)}
+	registerTestFunction(t, "fx_rate", 1, 1, func(arguments []Value) (Value, error) {
+		currency, ok := arguments[0].(Text)
+		if !ok {
+			return nil, TypeError{Expected: "a currency", Got: arguments[0]}
+		}
+		rate, ok := rates[currency]
+		if !ok {
+			return nil, ErrorValue{Kind: ErrorNotAvailable, Message: "no rate for " + string(currency)}
+		}
+		return rate, nil
+	})
+	registerTestFunction(t, "BRACKETS", 1, 0, func(arguments []Value) (Value, error) {
+		count := 0
+		for _, argument := range arguments {
+			if r, ok := argument.(RangeValue); ok {
+				r.Each(func(Value) bool { count++; return true })
+			}
+		}
+		return NewNumber(count), nil
+	})
+	registerTestFunction(t, "BROKEN", 0, 1, func(arguments []Value) (Value, error) {
+		if len(arguments) == 0 {
+			return nil, errors.New("out of order")
+		}
+		panic("not implemented")
+	})
+
+	workbook := NewWorkbook(3, 3)
+	setCells(t, workbook, map[string]string{
+		"A0": `"EUR"`,
+		"A1": "100 * FX_RATE(A0)",
+		"A2": `FX_RATE("USD")`,
+		"B0": "BRACKETS(A0:A1, 1)",
+		"B1": "BROKEN()",
+		"B2": "BROKEN(1)",
+		"C0": "FX_RATE(1 / 0)",
+	})
+	sheet := workbook.sheets[0]
+	for _, tt := range []struct {
+		Column, Row   int
+		Value, Kind   string
+		MessagePrefix string
+	}{
+		{Column: 0, Row: 1, Value: "200"},
+		{Column: 0, Row: 2, Kind: string(ErrorNotAvailable), MessagePrefix: "no rate for USD"},
+		{Column: 1, Row: 0, Value: "2"},
+		{Column: 1, Row: 1, Kind: string(ErrorValueType), MessagePrefix: "function BROKEN failed: out of order"},
+		{Column: 1, Row: 2, Kind: string(ErrorValueType), MessagePrefix: "function BROKEN failed: not implemented"},
+		{Column: 2, Row: 0, Kind: string(ErrorDivideByZero)},
+	} {
+		cell := sheet.Cell(tt.Column, tt.Row)
+		if got := cell.ErrorKind(); got != tt.Kind {
+			t.Errorf("expected %s to have error %q got %q %s", cell.Label(), tt.Kind, got, cell.ErrorMessage())
+		}
+		if tt.Value != "" && cell.String() != tt.Value {
+			t.Errorf("expected %s to be %s got %s", cell.Label(), tt.Value, cell.String())
+		}
+		if !strings.HasPrefix(cell.ErrorMessage(), tt.MessagePrefix) {
+			t.Errorf("expected the message of %s to start with %q got %q", cell.Label(), tt.MessagePrefix, cell.ErrorMessage())
+		}
+	}
+
+	// the arity is checked when the formula is parsed
+	if _, _, err := newExpression("FX_RATE(1, 2)", 2, 2); err == nil {
+		t.Error("expected too many arguments to fail")
+	}
+
+	// a registered function counts against the step budget like the built-in ones
+	setLimits(t, Limits{FormulaLength: DefaultLimits.FormulaLength, NestingDepth: DefaultLimits.NestingDepth, Steps: 3})
+	setCells(t, workbook, map[string]string{"C1": "FX_RATE(A0) + FX_RATE(A0)"})
+	if got := sheet.Cell(2, 1).ErrorKind(); got != string(ErrorCalculation) {
+		t.Errorf("expected the budget to stop the calculation got %q", got)
+	}
+}
+
+func TestRegisterFunction_errors(t *testing.T) {
+	registerTestFunction(t, "TAX", 1, 1, func([]Value) (Value, error) { return Number{}, nil })
+	identity := func(arguments []Value) (Value, error) { return arguments[0], nil }
+	for _, tt := range []struct {
+		Name                       string
+		MinArguments, MaxArguments int
+		Function                   Function
+		Error                      string
+	}{
+		{Name: "AB12", Function: identity, Error: "not start like a cell reference"},
+		{Name: "LOG10", Function: identity, Error: "not start like a cell reference"},
+		{Name: "$A1X", Function: identity, Error: "must start with a letter"},
+		{Name: "1X", Function: identity, Error: "must start with a letter"},
+		{Name: "FX-RATE", Function: identity, Error: "only contain letters"},
+		{Name: "", Function: identity, Error: "must start with a letter"},
+		{Name: "ROW", Function: identity, Error: "is a variable"},
+		{Name: "true", Function: identity, Error: "is a variable"},
+		{Name: "SUM", Function: identity, Error: "SUM already exists"},
+		{Name: "tax", Function: identity, Error: "TAX already exists"},
+		{Name: "RATE", Error: "has no implementation"},
+		{Name: "RATE", MinArguments: -1, Function: identity, Error: "negative"},
+		{Name: "RATE", MinArguments: 3, MaxArguments: 2, Function: identity, Error: "at least 3 arguments"},
+	} {
+		err := RegisterFunction(tt.Name, tt.MinArguments, tt.MaxArguments, tt.Function)
+		if err == nil || !strings.Contains(err.Error(), tt.Error) {
+			t.Errorf("expected registering %q to fail with %q got %v", tt.Name, tt.Error, err)
+		}
+	}
+	if _, ok := functions["RATE"]; ok {
+		t.Error("expected a failed registration not to add the function")
+	}
+}
@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"fmt"
+)
+
+// Axis is the direction rows or columns are inserted or deleted in.
+type Axis int
+
+const (
+	RowAxis Axis = iota
+	ColumnAxis
+)
+
+func (a Axis) String() string {
+	if a == ColumnAxis {
+		return "column"
+	}
+	return "row"
+}
+
+func (a Axis) size(table *Table) int {
+	if a == ColumnAxis {
+		return table.ColumnCount
+	}
+	return table.RowCount
+}
+
+// Resize inserts a row or column before index when delta is 1 and
+// deletes the row or column at index when delta is -1. The cells after the
+// index move and every reference to them, from any sheet, is rewritten.
+// References to a deleted cell become #REF!. The returned change undoes the
+// edit.
+func (workbook *Workbook) Resize(sheet *Table, a Axis, index, delta int) (*Change, error) {
+	size := a.size(sheet)
+	switch {
+	case delta > 0 && (index < 0 || index > size):
+		return nil, fmt.Errorf("%s %d is out of range it must be between 0 and %d", a, index, size)
+	case delta < 0 && (index < 0 || index >= size):
+		return nil, fmt.Errorf("%s %d is out of range it must be between 0 and %d", a, index, size-1)
+	case delta < 0 && size == 1:
+		return nil, fmt.Errorf("the last %s of sheet %s can not be deleted", a, sheet.Name)
+	}
+
+	c := new(Change)
+	for _, s := range workbook.sheets {
+		next := NewTable(s.ColumnCount, s.RowCount)
+		next.Name = s.Name
+		if s == sheet {
+			if a == ColumnAxis {
+				next.ColumnCount += delta
+			} else {
+				next.RowCount += delta
+			}
+		}
+		for cell := range s.Cells() {
+			column, row := cell.Column, cell.Row
+			if s == sheet {
+				var ok bool
+				column, row, ok = shiftPosition(a, column, row, index, delta)
+				if !ok {
+					continue
+				}
+			}
+			if cell.SavedExpression == nil {
+				continue
+			}
+			rewritten := mapReferences(cell.SavedExpression, func(node ExpressionNode) ExpressionNode {
+				return shiftReference(node, a, index, delta, s == sheet, sheet.Key())
+			})
+			exp, refs, err := newExpression(rewritten.String(), next.ColumnCount-1, next.RowCount-1)
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewrite %s: %w", cell.Label(), err)
+			}
+			next.SetCell(Cell{
+				Column:          column,
+				Row:             row,
+				Expression:      exp,
+				SavedExpression: exp,
+				References:      refs,
+				SavedReferences: refs,
+			})
+		}
+		c.addSheet(s, &next)
+	}
+	workbook.shiftNames(c, sheet, a, index, delta)
+	workbook.Apply(c, false)
+	return c, nil
+}
+
+// shiftPosition moves a cell of the resized sheet. It returns false for a
+// cell in a deleted row or column.
+func shiftPosition(a Axis, column, row, index, delta int) (int, int, bool) {
+	p := &row
+	if a == ColumnAxis {
+		p = &column
+	}
+	switch {
+	case delta < 0 && *p == index:
+		return 0, 0, false
+	case *p >= index:
+		*p += delta
+	}
+	return column, row, true
+}
+
+// shiftReference rewrites a reference to the resized sheet. A reference
+// without a sheet name refers to the sheet of the cell holding it, local is
+// set when that is the resized sheet. Ranges grow or shrink when a row or
+// column is inserted or deleted between their corners.
+func shiftReference(node ExpressionNode, a Axis, index, delta int, local bool, key string) ExpressionNode {
+	refersTo := func(identifier IdentifierNode) bool {
+		return identifier.Sheet == key || (identifier.Sheet == "" && local)
+	}
+	switch node := node.(type) {
+	case IdentifierNode:
+		if !refersTo(node) {
+			return node
+		}
+		p := a.coordinate(&node)
+		if delta < 0 && *p == index {
+			return referenceErrorNode()
+		}
+		if *p >= index {
+			*p += delta
+		}
+		return node.withPosition()
+	case RangeNode:
+		if !refersTo(node.From) {
+			return node
+		}
+		from, to := a.coordinate(&node.From), a.coordinate(&node.To)
+		low, high := min(*from, *to), max(*from, *to)
+		if delta < 0 && low == index && high == index {
+			return referenceErrorNode()
+		}
+		for _, p := range []*int{from, to} {
+			// the corner on the deleted row or column stays on the range
+			// side of the deletion
+			if *p > index || (*p == index && (delta > 0 || *p == high)) {
+				*p += delta
+			}
+		}
+		node.From, node.To = node.From.withPosition(), node.To.withPosition()
+		return node
+	default:
+		return node
+	}
+}
+
+func (a Axis) coordinate(node *IdentifierNode) *int {
+	if a == ColumnAxis {
+		return &node.Column
+	}
+	return &node.Row
+}
+
+// withPosition updates the token of the reference after its column or row
+// changed.
+func (node IdentifierNode) withPosition() IdentifierNode {
+	node.Token = Token{Type: TokenIdentifier, Value: cellReferenceText(node.Column, node.Row, node.AbsoluteColumn, node.AbsoluteRow)}
+	return node
+}
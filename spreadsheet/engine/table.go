@@ -0,0 +1,320 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// normalizeExpression upper cases the expression leaving the contents of
+// quoted text unchanged.
+func normalizeExpression(in string) string {
+	var (
+		sb     strings.Builder
+		quoted bool
+	)
+	sb.Grow(len(in))
+	for _, c := range strings.TrimSpace(in) {
+		if c == '"' {
+			quoted = !quoted
+		}
+		if !quoted {
+			c = unicode.ToUpper(c)
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// ColumnLabel returns the label of the zero based column number, A for 0,
+// Z for 25 and AA for 26.
+func ColumnLabel(n int) string {
+	result := ""
+	for n >= 0 {
+		remainder := n % 26
+		result = fmt.Sprintf("%c", remainder+65) + result
+		n = n/26 - 1
+	}
+	return result
+}
+
+// columnNumber returns the zero based number of a column label like AB. A
+// label too long to be numbered in an int returns math.MaxInt so it is
+// outside of every table.
+func columnNumber(label string) int {
+	result := 0
+	for _, char := range label {
+		if result > (math.MaxInt-26)/26 {
+			return math.MaxInt
+		}
+		result = result*26 + int(char) - 64
+	}
+	return result - 1
+}
+
+// Cell is a cell of a table. Expression is the parsed input and Value its
+// calculated value. The Saved fields hold the state of the last
+// calculation. Error is set when the input does not parse.
+type Cell struct {
+	Row    int
+	Column int
+
+	Expression,
+	SavedExpression ExpressionNode
+	Value,
+	SavedValue Value
+
+	References,
+	SavedReferences []CellIdentifier
+
+	input,
+	Error string
+}
+
+// ExpressionText returns the expression of the cell as it is edited, the
+// input when it did not parse.
+func (cell *Cell) ExpressionText() string {
+	if cell.Expression != nil && cell.Error == "" {
+		return cell.Expression.String()
+	}
+	return cell.input
+}
+
+// EncodedCell is the JSON encoding of a cell.
+type EncodedCell struct {
+	ID         string `json:"id"`
+	Expression string `json:"ex"`
+}
+
+func (cell *Cell) MarshalJSON() ([]byte, error) {
+	return json.Marshal(cell.encode())
+}
+
+func (cell *Cell) encode() EncodedCell {
+	return EncodedCell{
+		ID:         cell.Label(),
+		Expression: cell.SavedExpression.String(),
+	}
+}
+
+// EncodedTable is the JSON encoding of a table. Only the cells with an
+// expression are written.
+type EncodedTable struct {
+	Name        string        `json:"name,omitempty"`
+	ColumnCount int           `json:"columns"`
+	RowCount    int           `json:"rows"`
+	Cells       []EncodedCell `json:"cells"`
+}
+
+func (table *Table) MarshalJSON() ([]byte, error) {
+	return json.Marshal(table.encode())
+}
+
+func (table *Table) encode() EncodedTable {
+	encoded := EncodedTable{
+		Name:        table.Name,
+		ColumnCount: table.ColumnCount,
+		RowCount:    table.RowCount,
+		Cells:       make([]EncodedCell, 0, table.CellCount()),
+	}
+	for cell := range table.Cells() {
+		if cell.SavedExpression == nil || cell.Expression == nil {
+			continue
+		}
+		encoded.Cells = append(encoded.Cells, cell.encode())
+	}
+	return encoded
+}
+
+func (table *Table) UnmarshalJSON(in []byte) error {
+	var encoded EncodedTable
+
+	if err := json.Unmarshal(in, &encoded); err != nil {
+		return err
+	}
+	if err := table.decode(encoded); err != nil {
+		return err
+	}
+	table.calculateValues()
+	return nil
+}
+
+// decode sets the size and cells of the table without calculating values.
+func (table *Table) decode(encoded EncodedTable) error {
+	table.Name = encoded.Name
+	table.RowCount = encoded.RowCount
+	table.ColumnCount = encoded.ColumnCount
+	table.cells = nil
+	for _, cell := range encoded.Cells {
+		column, row, err := ParseCellID(cell.ID, table.ColumnCount-1, table.RowCount-1)
+		if err != nil {
+			return err
+		}
+		exp, refs, err := newExpression(cell.Expression, table.ColumnCount-1, table.RowCount-1)
+		if err != nil {
+			return err
+		}
+		table.SetCell(Cell{
+			Column:          column,
+			Row:             row,
+			SavedExpression: exp,
+			Expression:      exp,
+			References:      refs,
+			SavedReferences: refs,
+		})
+	}
+	return nil
+}
+
+// ErrorKind returns the kind of error the cell evaluated to or an empty
+// string when its value is not an error.
+func (cell *Cell) ErrorKind() string {
+	if errorValue, ok := cell.Value.(ErrorValue); ok {
+		return string(errorValue.Kind)
+	}
+	return ""
+}
+
+// ErrorMessage returns the message of the error the cell evaluated to.
+func (cell *Cell) ErrorMessage() string {
+	if errorValue, ok := cell.Value.(ErrorValue); ok {
+		return errorValue.Message
+	}
+	return ""
+}
+
+// String returns the calculated value of the cell as it is shown.
+func (cell *Cell) String() string {
+	if cell.SavedExpression == nil || cell.Value == nil {
+		return ""
+	}
+	return cell.Value.String()
+}
+
+// Label returns the identifier of the cell in expressions, like B3.
+func (cell *Cell) Label() string {
+	return fmt.Sprintf("%s%d", ColumnLabel(cell.Column), cell.Row)
+}
+
+// Table is a grid of cells. A table on its own calculates references to
+// its own cells; a table added to a Workbook with AddSheet is a sheet that
+// can reference the other sheets.
+type Table struct {
+	Name        string
+	ColumnCount int
+	RowCount    int
+
+	cells map[CellIdentifier]*Cell
+
+	// workbook is set when the table is a sheet of a workbook. It is used to
+	// look up the sheets named in cross sheet references.
+	workbook *Workbook
+}
+
+// NewTable returns an empty table with the number of columns and rows.
+func NewTable(columns, rows int) Table {
+	table := Table{
+		RowCount:    rows,
+		ColumnCount: columns,
+	}
+	return table
+}
+
+// calculateValues evaluates every cell. When the table is a sheet of a
+// workbook, every sheet of the workbook is calculated.
+func (table *Table) calculateValues() {
+	if table.workbook != nil {
+		table.workbook.calculateValues()
+		return
+	}
+	(&Workbook{sheets: []*Table{table}}).calculateValues()
+}
+
+// Key identifies the sheet in the dependency graph. Sheet names in
+// expressions are upper case so the key is as well.
+func (table *Table) Key() string {
+	return strings.ToUpper(table.Name)
+}
+
+// CellID returns the identifier of the cell at column and row that is
+// unique across all the sheets of a workbook.
+func (table *Table) CellID(column, row int) CellIdentifier {
+	return CellIdentifier{sheet: table.Key(), column: column, row: row}
+}
+
+// sheet returns the table a reference to the named sheet refers to. An empty
+// name refers to the table itself.
+func (table *Table) sheet(name string) (*Table, error) {
+	if name == "" || strings.EqualFold(name, table.Name) {
+		return table, nil
+	}
+	if table.workbook != nil {
+		if sheet := table.workbook.Sheet(name); sheet != nil {
+			return sheet, nil
+		}
+	}
+	return nil, newErrorValue(ErrorReference, "sheet %s not found", name)
+}
+
+var identifierPattern = regexp.MustCompile("^(?P<column>[A-Z]+)(?P<row>[0-9]+)$")
+
+// referencePattern matches a cell reference in an expression. A $ before the
+// column or the row makes that part absolute.
+var referencePattern = regexp.MustCompile(`^(?P<absoluteColumn>\$?)(?P<column>[A-Z]+)(?P<absoluteRow>\$?)(?P<row>[0-9]+)$`)
+
+// cellReferenceText writes a cell reference with $ markers for the absolute
+// parts.
+func cellReferenceText(column, row int, absoluteColumn, absoluteRow bool) string {
+	var sb strings.Builder
+	if absoluteColumn {
+		sb.WriteByte('$')
+	}
+	sb.WriteString(ColumnLabel(column))
+	if absoluteRow {
+		sb.WriteByte('$')
+	}
+	sb.WriteString(strconv.Itoa(row))
+	return sb.String()
+}
+
+// ParseCellID parses a cell identifier like B3 and returns its column and
+// row. It fails when the cell is beyond maxColumn or maxRow.
+func ParseCellID(in string, maxColumn, maxRow int) (int, int, error) {
+	if !identifierPattern.MatchString(in) {
+		return 0, 0, fmt.Errorf("unexpected identifier pattern expected something like A4")
+	}
+	parts := identifierPattern.FindStringSubmatch(in)
+	columnName := parts[identifierPattern.SubexpIndex("column")]
+	row, err := strconv.Atoi(parts[identifierPattern.SubexpIndex("row")])
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse row number: %w", err)
+	}
+	if row > maxRow {
+		return 0, 0, fmt.Errorf("row number %d out of range it must be greater than 0 and less than or equal to %d", row, maxRow)
+	}
+	column := columnNumber(columnName)
+	if column < 0 || column > maxColumn {
+		return 0, 0, fmt.Errorf("column %s out of range it must be greater than or equal to %s and less than or equal to %s", columnName, ColumnLabel(0), ColumnLabel(maxColumn))
+	}
+	return column, row, nil
+}
+
+// saveCellChange saves the calculated state of the cell at column and row. A
+// cell left without an expression is removed from the table.
+func (table *Table) saveCellChange(column, row int) {
+	cell, ok := table.cells[CellIdentifier{column: column, row: row}]
+	if !ok {
+		return
+	}
+	if cell.Expression == nil && cell.Error == "" {
+		table.DeleteCell(column, row)
+		return
+	}
+	cell.SavedValue = cell.Value
+	cell.SavedExpression = cell.Expression
+	cell.SavedReferences = cell.References
+}
@@ -0,0 +1,303 @@
+package engine
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Value is the result of evaluating an expression. A nil Value represents
+// an empty cell.
+type Value interface {
+	fmt.Stringer
+	TypeName() string
+}
+
+func (Number) TypeName() string { return "number" }
+
+// Text is a string value.
+type Text string
+
+func (text Text) String() string { return string(text) }
+
+func (Text) TypeName() string { return "text" }
+
+// Boolean is the result of a comparison or logical function.
+type Boolean bool
+
+func (b Boolean) String() string {
+	if b {
+		return TrueIdent
+	}
+	return FalseIdent
+}
+
+func (Boolean) TypeName() string { return "boolean" }
+
+// ErrorKind is the spreadsheet style name of an evaluation error.
+type ErrorKind string
+
+const (
+	ErrorDivideByZero ErrorKind = "#DIV/0!"
+	ErrorReference    ErrorKind = "#REF!"
+	ErrorCycle        ErrorKind = "#CYCLE!"
+	ErrorValueType    ErrorKind = "#VALUE!"
+	ErrorName         ErrorKind = "#NAME?"
+	ErrorNumber       ErrorKind = "#NUM!"
+	ErrorCalculation  ErrorKind = "#CALC!"
+	ErrorNotAvailable ErrorKind = "#N/A"
+)
+
+// errorKindPrefix returns the error kind the input starts with so error
+// values can be written in expressions.
+func errorKindPrefix(in string) (string, bool) {
+	for _, kind := range []ErrorKind{ErrorDivideByZero, ErrorReference, ErrorCycle, ErrorValueType, ErrorName, ErrorNumber, ErrorCalculation, ErrorNotAvailable} {
+		if strings.HasPrefix(in, string(kind)) {
+			return string(kind), true
+		}
+	}
+	return "", false
+}
+
+// ErrorValue is the value of a cell whose expression could not be
+// evaluated. It is also returned as an error from evaluate so it propagates
+// to every expression that depends on the cell.
+type ErrorValue struct {
+	Kind    ErrorKind
+	Message string
+}
+
+func newErrorValue(kind ErrorKind, format string, a ...any) ErrorValue {
+	return ErrorValue{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
+
+func (value ErrorValue) String() string { return string(value.Kind) }
+
+func (ErrorValue) TypeName() string { return "error" }
+
+func (value ErrorValue) Error() string {
+	return fmt.Sprintf("%s %s", value.Kind, value.Message)
+}
+
+// toErrorValue classifies an evaluation error.
+func toErrorValue(err error) ErrorValue {
+	var (
+		errorValue ErrorValue
+		typeError  TypeError
+	)
+	switch {
+	case errors.As(err, &errorValue):
+		return errorValue
+	case errors.As(err, &typeError):
+		return ErrorValue{Kind: ErrorValueType, Message: err.Error()}
+	case errors.Is(err, ErrDivideByZero{}):
+		return ErrorValue{Kind: ErrorDivideByZero, Message: err.Error()}
+	case errors.Is(err, ErrNumberOverflow{}):
+		return ErrorValue{Kind: ErrorNumber, Message: err.Error()}
+	default:
+		return ErrorValue{Kind: ErrorValueType, Message: err.Error()}
+	}
+}
+
+// RangeValue holds the values of the cells covered by a range. It is only
+// passed to functions. Only the cells holding something are kept so a range
+// over a large, mostly empty, sheet stays small.
+type RangeValue struct {
+	Node RangeNode
+
+	// Rows and Columns are the size of the range.
+	Rows, Columns int
+
+	// cells holds the non-empty values ordered by row and then by column.
+	cells []rangeCell
+}
+
+// rangeCell is a value at a row and column counted from the top left corner
+// of a range.
+type rangeCell struct {
+	row, column int
+	value       Value
+}
+
+func (value RangeValue) String() string { return value.Node.String() }
+
+func (RangeValue) TypeName() string { return "range" }
+
+// At returns the value at the row and column counted from 0 at the top left
+// corner of the range. It returns nil for an empty cell.
+func (value RangeValue) At(row, column int) Value {
+	i, found := slices.BinarySearchFunc(value.cells, rangeCell{row: row, column: column}, func(a, b rangeCell) int {
+		return cmp.Or(cmp.Compare(a.row, b.row), cmp.Compare(a.column, b.column))
+	})
+	if !found {
+		return nil
+	}
+	return value.cells[i].value
+}
+
+// Each iterates over the non-empty values in the range.
+func (value RangeValue) Each(yield func(Value) bool) {
+	for _, c := range value.cells {
+		if !yield(c.value) {
+			return
+		}
+	}
+}
+
+// EqualValues reports whether two values are the same. Unlike ==, it does
+// not panic when a value is a RangeValue.
+func EqualValues(a, b Value) bool {
+	ra, aIsRange := a.(RangeValue)
+	rb, bIsRange := b.(RangeValue)
+	if !aIsRange && !bIsRange {
+		return a == b
+	}
+	return aIsRange && bIsRange && ra.Node == rb.Node && slices.EqualFunc(ra.cells, rb.cells, func(x, y rangeCell) bool {
+		return x.row == y.row && x.column == y.column && EqualValues(x.value, y.value)
+	})
+}
+
+type TypeError struct {
+	Expected string
+	Got      Value
+}
+
+func (err TypeError) Error() string {
+	return fmt.Sprintf("type error: expected %s but got %s %s", err.Expected, err.Got.TypeName(), quoteValue(err.Got))
+}
+
+func quoteValue(value Value) string {
+	if text, ok := value.(Text); ok {
+		return quoteText(string(text))
+	}
+	return value.String()
+}
+
+func quoteText(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+}
+
+// toNumber converts a value to a number. Empty cells are zero.
+func toNumber(value Value) (Number, error) {
+	switch v := value.(type) {
+	case nil:
+		return Number{}, nil
+	case Number:
+		return v, nil
+	default:
+		return Number{}, TypeError{Expected: "a number", Got: value}
+	}
+}
+
+// toText converts a value to text. Empty cells are the empty string and
+// numbers are formatted the way they are displayed.
+func toText(value Value) (Text, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case Text:
+		return v, nil
+	case Number:
+		return Text(v.String()), nil
+	case Boolean:
+		return Text(v.String()), nil
+	default:
+		return "", TypeError{Expected: "text", Got: value}
+	}
+}
+
+// toBoolean converts a value to a boolean. Empty cells are false and
+// numbers are true when they are not zero.
+func toBoolean(value Value) (Boolean, error) {
+	switch v := value.(type) {
+	case nil:
+		return false, nil
+	case Boolean:
+		return v, nil
+	case Number:
+		return v.Sign() != 0, nil
+	default:
+		return false, TypeError{Expected: "a boolean", Got: value}
+	}
+}
+
+// compareValues applies a comparison operator using compareOrder.
+func compareValues(op Token, left, right Value) (Value, error) {
+	for _, value := range []Value{left, right} {
+		if r, ok := value.(RangeValue); ok {
+			return nil, TypeError{Expected: "a single value", Got: r}
+		}
+	}
+	result := compareOrder(left, right)
+	switch op.Type {
+	case TokenEqual:
+		return Boolean(result == 0), nil
+	case TokenNotEqual:
+		return Boolean(result != 0), nil
+	case TokenLess:
+		return Boolean(result < 0), nil
+	case TokenLessOrEqual:
+		return Boolean(result <= 0), nil
+	case TokenGreater:
+		return Boolean(result > 0), nil
+	case TokenGreaterOrEqual:
+		return Boolean(result >= 0), nil
+	default:
+		return nil, newErrorValue(ErrorName, "unknown comparison operator %s", op.Value)
+	}
+}
+
+// compareOrder returns -1, 0 or 1 when left is less than, equal to or
+// greater than right. Text is compared without regard to case. Values of
+// different types are ordered numbers first, then text and then booleans.
+// An empty cell compares like the zero value of the other operand's type.
+func compareOrder(left, right Value) int {
+	if left == nil {
+		left = zeroValue(right)
+	}
+	if right == nil {
+		right = zeroValue(left)
+	}
+	result := cmp.Compare(typeOrder(left), typeOrder(right))
+	if result != 0 {
+		return result
+	}
+	switch l := left.(type) {
+	case Number:
+		return l.Cmp(right.(Number))
+	case Text:
+		return strings.Compare(strings.ToLower(string(l)), strings.ToLower(string(right.(Text))))
+	case Boolean:
+		if l != right.(Boolean) {
+			if l {
+				return 1
+			}
+			return -1
+		}
+	}
+	return 0
+}
+
+func typeOrder(value Value) int {
+	switch value.(type) {
+	case Text:
+		return 1
+	case Boolean:
+		return 2
+	default:
+		return 0
+	}
+}
+
+func zeroValue(other Value) Value {
+	switch other.(type) {
+	case Text:
+		return Text("")
+	case Boolean:
+		return Boolean(false)
+	default:
+		return Number{}
+	}
+}
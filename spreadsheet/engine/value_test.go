@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func Test_textExpressions(t *testing.T) {
+	var table Table
+	if err := json.Unmarshal([]byte(`{"columns":3,"rows":3,"cells":[
+		{"id":"A0","ex":"\"Revenue\""},
+		{"id":"A1","ex":"10"},
+		{"id":"A2","ex":"2.5"},
+		{"id":"B0","ex":"\"Cost\""}
+	]}`), &table); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		Expression string
+		Result     Value
+	}{
+		{Expression: `"hello"`, Result: Text("hello")},
+		{Expression: `"Hello, World!"`, Result: Text("Hello, World!")},
+		{Expression: `"say ""hi"""`, Result: Text(`say "hi"`)},
+		{Expression: `"a" & "b"`, Result: Text("ab")},
+		{Expression: `A0 & ": " & A1`, Result: Text("Revenue: 10")},
+		{Expression: `"total " & 1 + 2`, Result: Text("total 3")},
+		{Expression: `"x" & C2`, Result: Text("x")},
+		{Expression: `LEN(A0)`, Result: NewNumber(7)},
+		{Expression: `LEN("héllo")`, Result: NewNumber(5)},
+		{Expression: `UPPER(A0)`, Result: Text("REVENUE")},
+		{Expression: `lower("MiXeD")`, Result: Text("mixed")},
+		{Expression: `CONCAT(A0:B0, "-", A1)`, Result: Text("RevenueCost-10")},
+		{Expression: `SUM(A0:A2)`, Result: Number{coefficient: 125, scale: 1}},
+		{Expression: `COUNT(A0:B2)`, Result: NewNumber(2)},
+	} {
+		t.Run(tt.Expression, func(t *testing.T) {
+			exp, _, err := newExpression(tt.Expression, 2, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			value, err := evaluate(&table, &Cell{Column: 2, Row: 2}, newWalkState(0), exp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != tt.Result {
+				t.Errorf("expected %s %q got %s %q", tt.Result.TypeName(), tt.Result, value.TypeName(), value)
+			}
+		})
+	}
+}
+
+func Test_textTypeErrors(t *testing.T) {
+	for _, expression := range []string{
+		`"a" + 1`,
+		`1 * "b"`,
+		`"3"!`,
+		`SUM("a", 1)`,
+		`QUOTIENT("4", 2)`,
+	} {
+		t.Run(expression, func(t *testing.T) {
+			exp, _, err := newExpression(expression, 2, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			table := NewTable(3, 3)
+			_, err = evaluate(&table, &Cell{}, newWalkState(0), exp)
+			var typeError TypeError
+			if !errors.As(err, &typeError) {
+				t.Errorf("expected a type error got %v", err)
+			}
+		})
+	}
+}
+
+func Test_normalizeExpression(t *testing.T) {
+	for in, exp := range map[string]string{
+		`  a0 + b1 `:            `A0 + B1`,
+		`"Mixed Case" & a0`:     `"Mixed Case" & A0`,
+		`upper("x ""y"" z")`:    `UPPER("x ""y"" z")`,
+		`"a" & lower("B") & c1`: `"a" & LOWER("B") & C1`,
+	} {
+		if got := normalizeExpression(in); got != exp {
+			t.Errorf("expected %s got %s", exp, got)
+		}
+	}
+}
+
+func Test_tokenize_unterminatedText(t *testing.T) {
+	if _, err := tokenize(`"abc`); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestTable_JSON_text(t *testing.T) {
+	const in = `{"columns":2,"rows":2,"cells":[{"id":"A0","ex":"\"Name \"\"quoted\"\"\""},{"id":"B0","ex":"A0 \u0026 \"!\""}]}`
+	var table Table
+	if err := json.Unmarshal([]byte(in), &table); err != nil {
+		t.Fatal(err)
+	}
+	if got := table.Cell(1, 0).String(); got != `Name "quoted"!` {
+		t.Errorf("unexpected B0 value %s", got)
+	}
+	out, err := json.Marshal(&table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != in {
+		t.Errorf("unexpected JSON\nexp: %s\ngot: %s", in, out)
+	}
+}
+
+func TestEqualValues(t *testing.T) {
+	r := RangeValue{Rows: 2, Columns: 1, cells: []rangeCell{{value: NewNumber(1)}}}
+	for _, tt := range []struct {
+		A, B  Value
+		Equal bool
+	}{
+		{A: NewNumber(1), B: NewNumber(1), Equal: true},
+		{A: Text("1"), B: NewNumber(1)},
+		{A: nil, B: nil, Equal: true},
+		{A: r, B: r, Equal: true},
+		{A: r, B: RangeValue{Rows: 2, Columns: 1}},
+		{A: r, B: NewNumber(1)},
+	} {
+		if got := EqualValues(tt.A, tt.B); got != tt.Equal {
+			t.Errorf("expected EqualValues(%v, %v) to be %t", tt.A, tt.B, tt.Equal)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const defaultSheetName = "Sheet1"
+
+// Workbook holds the named sheets of a spreadsheet. Expressions reference
+// cells of other sheets by name, like SHEET2!B3, so the workbook keeps one
+// dependency graph for all of its sheets.
+type Workbook struct {
+	sheets []*Table
+
+	// dependencies maps each cell to the cells its expression references,
+	// dependents is the reverse mapping.
+	dependencies,
+	dependents map[CellIdentifier]map[CellIdentifier]struct{}
+
+	// rangeDependents holds the ranges cells reference. A range is kept as
+	// a single edge so the graph does not grow with the number of cells it
+	// covers.
+	rangeDependents rangeIndex
+
+	// names maps each defined name to the IdentifierNode or RangeNode it
+	// refers to. The references always name their sheet.
+	names map[string]ExpressionNode
+
+	// iteration is nil until iterative calculation is configured.
+	iteration *Iteration
+}
+
+// NewWorkbook returns a workbook with a single sheet.
+func NewWorkbook(columns, rows int) *Workbook {
+	workbook := new(Workbook)
+	workbook.sheets = []*Table{{Name: defaultSheetName, ColumnCount: columns, RowCount: rows, workbook: workbook}}
+	return workbook
+}
+
+// Sheets returns the sheets in the order they were added.
+func (workbook *Workbook) Sheets() []*Table {
+	return workbook.sheets
+}
+
+// Sheet returns the sheet with the name ignoring case or nil when there is
+// no such sheet.
+func (workbook *Workbook) Sheet(name string) *Table {
+	for _, sheet := range workbook.sheets {
+		if strings.EqualFold(sheet.Name, name) {
+			return sheet
+		}
+	}
+	return nil
+}
+
+func (workbook *Workbook) sheetByKey(key string) *Table {
+	for _, sheet := range workbook.sheets {
+		if sheet.Key() == key {
+			return sheet
+		}
+	}
+	return nil
+}
+
+var sheetNamePattern = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+func (workbook *Workbook) checkSheetName(name string) error {
+	if !sheetNamePattern.MatchString(name) {
+		return fmt.Errorf("sheet name %q must start with a letter and only contain letters, digits and underscores", name)
+	}
+	if workbook.Sheet(name) != nil {
+		return fmt.Errorf("sheet %s already exists", name)
+	}
+	return nil
+}
+
+// AddSheet adds an empty sheet. Cells that already reference a sheet with
+// the name are calculated again.
+func (workbook *Workbook) AddSheet(name string, columns, rows int) (*Table, *Change, error) {
+	if err := workbook.checkSheetName(name); err != nil {
+		return nil, nil, err
+	}
+	if columns < 1 || rows < 1 {
+		return nil, nil, fmt.Errorf("sheet %s must have at least one column and one row", name)
+	}
+	sheet := &Table{Name: name, ColumnCount: columns, RowCount: rows}
+	c := &Change{previousSheets: slices.Clone(workbook.sheets), nextSheets: append(slices.Clone(workbook.sheets), sheet)}
+	workbook.Apply(c, false)
+	return sheet, c, nil
+}
+
+// RenameSheet renames a sheet and updates the expressions that reference it.
+func (workbook *Workbook) RenameSheet(name, newName string) (*Change, error) {
+	sheet := workbook.Sheet(name)
+	if sheet == nil {
+		return nil, fmt.Errorf("sheet %s not found", name)
+	}
+	if !strings.EqualFold(name, newName) {
+		if err := workbook.checkSheetName(newName); err != nil {
+			return nil, err
+		}
+	}
+	oldKey, newKey := sheet.Key(), strings.ToUpper(newName)
+	rename := func(node IdentifierNode) IdentifierNode {
+		if node.Sheet == oldKey {
+			node.Sheet = newKey
+		}
+		return node
+	}
+	c := &Change{renames: []sheetRename{{sheet: sheet, previous: sheet.Name, next: newName}}}
+	if len(workbook.names) > 0 {
+		next := make(map[string]ExpressionNode, len(workbook.names))
+		for name, reference := range workbook.names {
+			next[name] = mapIdentifiers(reference, rename)
+		}
+		c.names = &nameChange{previous: maps.Clone(workbook.names), next: next}
+	}
+	for _, s := range workbook.sheets {
+		for cell := range s.Cells() {
+			if cell.SavedExpression == nil {
+				continue
+			}
+			renamed := mapIdentifiers(cell.SavedExpression, rename)
+			exp, refs, err := newExpression(renamed.String(), s.ColumnCount-1, s.RowCount-1)
+			if err != nil {
+				return nil, err
+			}
+			c.addCell(s, cell.Column, cell.Row, savedCellState(cell), cellState{expression: exp, references: refs})
+		}
+	}
+	workbook.Apply(c, false)
+	return c, nil
+}
+
+// DeleteSheet removes a sheet. Expressions referencing the sheet are kept
+// and evaluate to a reference error. The last sheet can not be deleted.
+func (workbook *Workbook) DeleteSheet(name string) (*Change, error) {
+	for i, sheet := range workbook.sheets {
+		if !strings.EqualFold(sheet.Name, name) {
+			continue
+		}
+		if len(workbook.sheets) == 1 {
+			return nil, fmt.Errorf("sheet %s is the only sheet", sheet.Name)
+		}
+		c := &Change{previousSheets: slices.Clone(workbook.sheets), nextSheets: slices.Delete(slices.Clone(workbook.sheets), i, i+1)}
+		workbook.Apply(c, false)
+		return c, nil
+	}
+	return nil, fmt.Errorf("sheet %s not found", name)
+}
+
+// mapIdentifiers returns a copy of the expression with every cell reference,
+// including the corners of ranges, replaced by the result of fn.
+func mapIdentifiers(node ExpressionNode, fn func(IdentifierNode) IdentifierNode) ExpressionNode {
+	return mapReferences(node, func(node ExpressionNode) ExpressionNode {
+		switch node := node.(type) {
+		case IdentifierNode:
+			return fn(node)
+		case RangeNode:
+			node.From, node.To = fn(node.From), fn(node.To)
+			return node
+		default:
+			return node
+		}
+	})
+}
+
+// mapReferences returns a copy of the expression with every IdentifierNode,
+// RangeNode and NameNode replaced by the result of fn. Unlike
+// mapIdentifiers, fn may replace a reference with any expression, for
+// example an ErrorNode.
+func mapReferences(node ExpressionNode, fn func(ExpressionNode) ExpressionNode) ExpressionNode {
+	switch node := node.(type) {
+	case IdentifierNode, RangeNode, NameNode:
+		return fn(node)
+	case ParenNode:
+		node.Node = mapReferences(node.Node, fn)
+		return node
+	case FactorialNode:
+		node.Expression = mapReferences(node.Expression, fn)
+		return node
+	case UnaryExpressionNode:
+		node.Expression = mapReferences(node.Expression, fn)
+		return node
+	case BinaryExpressionNode:
+		node.Left = mapReferences(node.Left, fn)
+		node.Right = mapReferences(node.Right, fn)
+		return node
+	case FunctionNode:
+		arguments := make([]ExpressionNode, len(node.Arguments))
+		for i, argument := range node.Arguments {
+			arguments[i] = mapReferences(argument, fn)
+		}
+		node.Arguments = arguments
+		return node
+	default:
+		return node
+	}
+}
+
+type EncodedWorkbook struct {
+	Sheets    []EncodedTable    `json:"sheets"`
+	Names     []EncodedName     `json:"names,omitempty"`
+	Iteration *EncodedIteration `json:"iteration,omitempty"`
+}
+
+func (workbook *Workbook) MarshalJSON() ([]byte, error) {
+	encoded := EncodedWorkbook{Sheets: make([]EncodedTable, 0, len(workbook.sheets)), Names: workbook.encodeNames(), Iteration: workbook.encodeIteration()}
+	for _, sheet := range workbook.sheets {
+		encoded.Sheets = append(encoded.Sheets, sheet.encode())
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON reads a workbook. A file holding a single table, without
+// the sheets field, is read as a workbook with one sheet.
+func (workbook *Workbook) UnmarshalJSON(in []byte) error {
+	var encoded struct {
+		EncodedWorkbook
+		EncodedTable
+	}
+	if err := json.Unmarshal(in, &encoded); err != nil {
+		return err
+	}
+	sheets := encoded.Sheets
+	if sheets == nil {
+		sheets = []EncodedTable{encoded.EncodedTable}
+	}
+	if len(sheets) == 0 {
+		return fmt.Errorf("workbook has no sheets")
+	}
+	workbook.sheets = nil
+	for i, encodedSheet := range sheets {
+		if encodedSheet.Name == "" {
+			encodedSheet.Name = "Sheet" + strconv.Itoa(i+1)
+		}
+		if err := workbook.checkSheetName(encodedSheet.Name); err != nil {
+			return err
+		}
+		sheet := &Table{workbook: workbook}
+		if err := sheet.decode(encodedSheet); err != nil {
+			return fmt.Errorf("sheet %s: %w", encodedSheet.Name, err)
+		}
+		workbook.sheets = append(workbook.sheets, sheet)
+	}
+	if err := workbook.decodeNames(encoded.Names); err != nil {
+		return err
+	}
+	if err := workbook.decodeIteration(encoded.Iteration); err != nil {
+		return err
+	}
+	workbook.calculateValues()
+	return nil
+}
@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_sheetReferences(t *testing.T) {
+	for _, tt := range []struct {
+		Expression string
+		String     string
+		References []CellIdentifier
+	}{
+		{Expression: "Sheet2!B3", String: "SHEET2!B3", References: []CellIdentifier{{sheet: "SHEET2", column: 1, row: 3}}},
+		{Expression: "sum(data!A0:A1) + A0", String: "SUM(DATA!A0:A1) + A0", References: []CellIdentifier{{column: 0, row: 0}}},
+		{Expression: "OTHER!Z99", String: "OTHER!Z99", References: []CellIdentifier{{sheet: "OTHER", column: 25, row: 99}}},
+		{Expression: "A0! + 1", String: "A0! + 1", References: []CellIdentifier{{column: 0, row: 0}}},
+	} {
+		t.Run(tt.Expression, func(t *testing.T) {
+			exp, refs, err := newExpression(tt.Expression, 2, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := exp.String(); got != tt.String {
+				t.Errorf("expected %s got %s", tt.String, got)
+			}
+			if len(refs) != len(tt.References) {
+				t.Fatalf("expected references %v got %v", tt.References, refs)
+			}
+			for i := range refs {
+				if refs[i] != tt.References[i] {
+					t.Errorf("expected references %v got %v", tt.References, refs)
+				}
+			}
+		})
+	}
+
+	if _, _, err := newExpression("DATA! A0", 2, 2); err == nil {
+		t.Errorf("expected a space after the exclamation mark to not be a sheet reference")
+	}
+}
+
+func TestWorkbook_JSON(t *testing.T) {
+	t.Run("single table", func(t *testing.T) {
+		var workbook Workbook
+		if err := json.Unmarshal([]byte(`{"columns":2,"rows":2,"cells":[{"id":"A0","ex":"1"},{"id":"B0","ex":"A0 + 1"}]}`), &workbook); err != nil {
+			t.Fatal(err)
+		}
+		if len(workbook.Sheets()) != 1 || workbook.Sheets()[0].Name != defaultSheetName {
+			t.Fatalf("expected a single sheet named %s", defaultSheetName)
+		}
+		if got := workbook.Sheets()[0].Cell(1, 0).Value; got != NewNumber(2) {
+			t.Errorf("expected B0 to be 2 got %s", got)
+		}
+	})
+
+	t.Run("sheets", func(t *testing.T) {
+		in := `{"sheets":[{"name":"Summary","columns":1,"rows":1,"cells":[{"id":"A0","ex":"COSTS!A0 + COSTS!A1"}]},{"name":"Costs","columns":1,"rows":2,"cells":[{"id":"A0","ex":"1.5"},{"id":"A1","ex":"2"}]}]}`
+		var workbook Workbook
+		if err := json.Unmarshal([]byte(in), &workbook); err != nil {
+			t.Fatal(err)
+		}
+		if got := workbook.Sheet("Summary").Cell(0, 0).Value; got != (Number{coefficient: 35, scale: 1}) {
+			t.Errorf("expected the reference to a later sheet to be 3.5 got %s", got)
+		}
+		out, err := json.Marshal(&workbook)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != in {
+			t.Errorf("unexpected JSON\nexp: %s\ngot: %s", in, out)
+		}
+	})
+
+	t.Run("duplicate sheet names", func(t *testing.T) {
+		var workbook Workbook
+		err := json.Unmarshal([]byte(`{"sheets":[{"name":"A","columns":1,"rows":1,"cells":[]},{"name":"a","columns":1,"rows":1,"cells":[]}]}`), &workbook)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestWorkbook_sheetRange(t *testing.T) {
+	workbook := NewWorkbook(2, 2)
+	data, _, err := workbook.AddSheet("S2", 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setCells(t, workbook, map[string]string{
+		"A0": "SUM(S2!A0:A2)",
+		"B0": "SUM(S2!A0:A200000000)",
+	})
+	if got := workbook.Sheets()[0].Cell(1, 0).ErrorKind(); got != string(ErrorReference) {
+		t.Errorf("expected a range past the end of the sheet to be %s got %q", ErrorReference, got)
+	}
+
+	workbook.EditCells(data, []CellEdit{{Column: 0, Row: 2, Input: "5"}})
+	if got := workbook.Sheets()[0].Cell(0, 0).Value; got != NewNumber(5) {
+		t.Errorf("expected an edit on the other sheet to update the sum to 5 got %s", got)
+	}
+}
@@ -0,0 +1,586 @@
+package engine
+
+import (
+	"archive/zip"
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Excel rows start at 1 while the rows of this spreadsheet start at 0, so A0
+// is written to the workbook as A1. Formulas are translated where the
+// expression language maps onto Excel's; other cells are written with only
+// their calculated value.
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+)
+
+type xlsxWorksheet struct {
+	XMLName   xml.Name       `xml:"http://schemas.openxmlformats.org/spreadsheetml/2006/main worksheet"`
+	Dimension *xlsxDimension `xml:"dimension"`
+	Rows      []xlsxRow      `xml:"sheetData>row"`
+}
+
+type xlsxDimension struct {
+	Ref string `xml:"ref,attr"`
+}
+
+type xlsxRow struct {
+	Number int        `xml:"r,attr"`
+	Cells  []xlsxCell `xml:"c"`
+}
+
+type xlsxCell struct {
+	Ref          string      `xml:"r,attr"`
+	Type         string      `xml:"t,attr,omitempty"`
+	Formula      string      `xml:"f,omitempty"`
+	Value        string      `xml:"v,omitempty"`
+	InlineString *xlsxString `xml:"is"`
+}
+
+// xlsxString is either a shared string item or an inline string. Rich text
+// is stored as runs.
+type xlsxString struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (s xlsxString) String() string {
+	if len(s.Runs) == 0 {
+		return s.Text
+	}
+	var sb strings.Builder
+	for _, run := range s.Runs {
+		sb.WriteString(run.Text)
+	}
+	return sb.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxString `xml:"si"`
+}
+
+type xlsxWorkbookSheets struct {
+	Sheets []struct {
+		RelationshipID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationshipList struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// WriteXLSX writes the table as a workbook with a single worksheet.
+func (table *Table) WriteXLSX(w io.Writer) error {
+	sheet := xlsxWorksheet{
+		Dimension: &xlsxDimension{Ref: "A1:" + excelCellID(max(table.ColumnCount, 1)-1, max(table.RowCount, 1)-1)},
+	}
+	for cell := range table.Cells() {
+		c, ok := cell.xlsxCell()
+		if !ok {
+			continue
+		}
+		if n := len(sheet.Rows); n == 0 || sheet.Rows[n-1].Number != cell.Row+1 {
+			sheet.Rows = append(sheet.Rows, xlsxRow{Number: cell.Row + 1})
+		}
+		row := &sheet.Rows[len(sheet.Rows)-1]
+		row.Cells = append(row.Cells, c)
+	}
+	sheetXML, err := xml.Marshal(sheet)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	for _, file := range []struct {
+		name    string
+		content []byte
+	}{
+		{name: "[Content_Types].xml", content: []byte(xlsxContentTypes)},
+		{name: "_rels/.rels", content: []byte(xlsxRelationships)},
+		{name: "xl/workbook.xml", content: []byte(xlsxWorkbook)},
+		{name: "xl/_rels/workbook.xml.rels", content: []byte(xlsxWorkbookRelationships)},
+		{name: "xl/worksheets/sheet1.xml", content: append([]byte(xml.Header), sheetXML...)},
+	} {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(file.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func (cell *Cell) xlsxCell() (xlsxCell, bool) {
+	c := xlsxCell{Ref: excelCellID(cell.Column, cell.Row)}
+	switch node := cell.SavedExpression.(type) {
+	case nil:
+		return c, false
+	case TextNode:
+		c.Type = "inlineStr"
+		c.InlineString = &xlsxString{Text: node.Token.Value}
+		return c, true
+	case NumberNode:
+		c.Value = node.Value.String()
+		return c, true
+	}
+	if formula, ok := excelFormula(cell.SavedExpression); ok {
+		c.Formula = formula
+	}
+	switch value := cell.SavedValue.(type) {
+	case Text:
+		if c.Formula != "" {
+			c.Type = "str"
+			c.Value = string(value)
+		} else {
+			c.Type = "inlineStr"
+			c.InlineString = &xlsxString{Text: string(value)}
+		}
+	case Boolean:
+		c.Type = "b"
+		c.Value = "0"
+		if value {
+			c.Value = "1"
+		}
+	case ErrorValue:
+		c.Type = "e"
+		c.Value = string(value.Kind)
+		if value.Kind == ErrorCycle {
+			// Excel has no error value for circular references
+			c.Value = string(ErrorReference)
+		}
+	case Number:
+		c.Value = value.String()
+	}
+	return c, true
+}
+
+// ReadTableXLSX reads the first worksheet of a workbook. The table is large
+// enough to hold the dimension of the worksheet and every cell in it.
+// Formulas that can not be translated are replaced by the value Excel
+// calculated for the cell.
+func ReadTableXLSX(r io.ReaderAt, size int64) (Table, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return Table{}, err
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+	sheetPath, err := xlsxFirstSheetPath(files)
+	if err != nil {
+		return Table{}, err
+	}
+	var sharedStrings xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := readXLSXPart(files, "xl/sharedStrings.xml", &sharedStrings); err != nil {
+			return Table{}, err
+		}
+	}
+	var sheet xlsxWorksheet
+	if err := readXLSXPart(files, sheetPath, &sheet); err != nil {
+		return Table{}, err
+	}
+
+	table := NewTable(1, 1)
+	if sheet.Dimension != nil {
+		_, last, _ := strings.Cut(sheet.Dimension.Ref, ":")
+		if column, row, err := parseExcelCellID(cmp.Or(last, sheet.Dimension.Ref)); err == nil {
+			table.ColumnCount, table.RowCount = max(table.ColumnCount, column+1), max(table.RowCount, row+1)
+		}
+	}
+	for _, row := range sheet.Rows {
+		for _, c := range row.Cells {
+			column, row, err := parseExcelCellID(c.Ref)
+			if err != nil {
+				return Table{}, fmt.Errorf("cell %s: %w", c.Ref, err)
+			}
+			table.ColumnCount, table.RowCount = max(table.ColumnCount, column+1), max(table.RowCount, row+1)
+		}
+	}
+	for _, row := range sheet.Rows {
+		for _, c := range row.Cells {
+			column, row, _ := parseExcelCellID(c.Ref)
+			exp, refs, err := c.expression(sharedStrings, table.ColumnCount-1, table.RowCount-1)
+			if err != nil {
+				return Table{}, fmt.Errorf("cell %s: %w", c.Ref, err)
+			}
+			if exp == nil {
+				continue
+			}
+			table.SetCell(Cell{
+				Column:          column,
+				Row:             row,
+				SavedExpression: exp,
+				Expression:      exp,
+				References:      refs,
+				SavedReferences: refs,
+			})
+		}
+	}
+	table.calculateValues()
+	return table, nil
+}
+
+func xlsxFirstSheetPath(files map[string]*zip.File) (string, error) {
+	var workbook xlsxWorkbookSheets
+	if err := readXLSXPart(files, "xl/workbook.xml", &workbook); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", errors.New("workbook has no sheets")
+	}
+	var relationships xlsxRelationshipList
+	if err := readXLSXPart(files, "xl/_rels/workbook.xml.rels", &relationships); err != nil {
+		return "", err
+	}
+	for _, relationship := range relationships.Relationships {
+		if relationship.ID != workbook.Sheets[0].RelationshipID {
+			continue
+		}
+		if target, ok := strings.CutPrefix(relationship.Target, "/"); ok {
+			return target, nil
+		}
+		return path.Join("xl", relationship.Target), nil
+	}
+	return "", fmt.Errorf("workbook relationship %s not found", workbook.Sheets[0].RelationshipID)
+}
+
+// maxXLSXPartSize is the most bytes decompressed from a part of a workbook
+// so a small archive can not expand to fill the memory.
+var maxXLSXPartSize int64 = 64 << 20
+
+func readXLSXPart(files map[string]*zip.File, name string, v any) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("workbook is missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer closeAndIgnoreError(rc)
+	part := &io.LimitedReader{R: rc, N: maxXLSXPartSize + 1}
+	if err := xml.NewDecoder(part).Decode(v); err != nil {
+		if part.N == 0 {
+			return fmt.Errorf("workbook part %s is larger than %d bytes", name, maxXLSXPartSize)
+		}
+		return fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return nil
+}
+
+func (c xlsxCell) expression(sharedStrings xlsxSharedStrings, maxColumn, maxRow int) (ExpressionNode, []CellIdentifier, error) {
+	if c.Formula != "" {
+		if exp, refs, err := excelExpression(c.Formula, maxColumn, maxRow); err == nil {
+			return exp, refs, nil
+		}
+	}
+	switch c.Type {
+	case "s":
+		i, err := strconv.Atoi(c.Value)
+		if err != nil || i < 0 || i >= len(sharedStrings.Items) {
+			return nil, nil, fmt.Errorf("unknown shared string %q", c.Value)
+		}
+		return TextNode{Token: Token{Type: TokenString, Value: sharedStrings.Items[i].String()}}, nil, nil
+	case "inlineStr":
+		if c.InlineString == nil {
+			return nil, nil, nil
+		}
+		return TextNode{Token: Token{Type: TokenString, Value: c.InlineString.String()}}, nil, nil
+	case "e":
+		// errors this spreadsheet does not have, like #NULL!, stay text
+		if kind, ok := errorKindPrefix(c.Value); ok {
+			return ErrorNode{Token: Token{Type: TokenError, Value: kind}}, nil, nil
+		}
+		return TextNode{Token: Token{Type: TokenString, Value: c.Value}}, nil, nil
+	case "str":
+		return TextNode{Token: Token{Type: TokenString, Value: c.Value}}, nil, nil
+	case "b":
+		if c.Value == "1" {
+			return BooleanNode{Token: Token{Type: TokenIdentifier, Value: TrueIdent}, Value: true}, nil, nil
+		}
+		return BooleanNode{Token: Token{Type: TokenIdentifier, Value: FalseIdent}}, nil, nil
+	}
+	if c.Value == "" {
+		return nil, nil, nil
+	}
+	n, err := parseExcelNumber(c.Value)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NumberNode{Token: Token{Type: TokenNumber, Value: n.String()}, Value: n}, nil, nil
+}
+
+// parseExcelNumber parses a cached number value. Excel writes values like
+// 0.30000000000000004 or 1E-3 so numbers that are not plain decimals are
+// parsed as floats first.
+func parseExcelNumber(in string) (Number, error) {
+	if n, err := ParseNumber(in); err == nil {
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(in, 64)
+	if err != nil {
+		return Number{}, fmt.Errorf("failed to parse number %q", in)
+	}
+	return ParseNumber(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+func excelCellID(column, row int) string {
+	return ColumnLabel(column) + strconv.Itoa(row+1)
+}
+
+// excelReference writes a reference keeping its $ markers.
+func excelReference(node IdentifierNode) string {
+	return cellReferenceText(node.Column, node.Row+1, node.AbsoluteColumn, node.AbsoluteRow)
+}
+
+func parseExcelCellID(in string) (int, int, error) {
+	parts := identifierPattern.FindStringSubmatch(in)
+	if parts == nil {
+		return 0, 0, fmt.Errorf("unexpected cell reference %q", in)
+	}
+	row, err := strconv.Atoi(parts[identifierPattern.SubexpIndex("row")])
+	if err != nil || row < 1 {
+		return 0, 0, fmt.Errorf("unexpected cell reference %q", in)
+	}
+	return columnNumber(parts[identifierPattern.SubexpIndex("column")]), row - 1, nil
+}
+
+// excelFunctionNames maps function names that are spelled differently in
+// Excel. Every other function has the same name.
+var excelFunctionNames = map[string]string{
+	"AVG": "AVERAGE",
+}
+
+// excelFormula translates an expression to an Excel formula. It returns
+// false when the expression uses something Excel does not have, like the
+// table variables, or references another sheet, which is not written.
+func excelFormula(node ExpressionNode) (string, bool) {
+	switch node := node.(type) {
+	case IdentifierNode:
+		return excelReference(node), node.Sheet == ""
+	case RangeNode:
+		return excelReference(node.From) + ":" + excelReference(node.To), node.From.Sheet == ""
+	case ErrorNode:
+		return node.Token.Value, true
+	case NumberNode:
+		return node.Value.String(), true
+	case TextNode, BooleanNode:
+		return node.String(), true
+	case ParenNode:
+		inner, ok := excelFormula(node.Node)
+		return "(" + inner + ")", ok
+	case FactorialNode:
+		operand := node.Expression
+		if paren, isParen := operand.(ParenNode); isParen {
+			operand = paren.Node
+		}
+		inner, ok := excelFormula(operand)
+		return "FACT(" + inner + ")", ok
+	case UnaryExpressionNode:
+		// Excel negates before it raises to a power, -2^2 is 4
+		inner, ok := excelOperand(node.Expression, isExponent(node.Expression))
+		return node.Op.Value + inner, ok
+	case BinaryExpressionNode:
+		// Excel raises to a power from left to right and negates first so
+		// operands of ^ are grouped explicitly
+		exponent := node.Op.Type == TokenExponent
+		left, leftOK := excelOperand(node.Left, exponent)
+		right, rightOK := excelOperand(node.Right, exponent)
+		return left + node.Op.Value + right, leftOK && rightOK
+	case FunctionNode:
+		arguments := make([]string, 0, len(node.Arguments))
+		for _, argument := range node.Arguments {
+			s, ok := excelFormula(argument)
+			if !ok {
+				return "", false
+			}
+			arguments = append(arguments, s)
+		}
+		return cmp.Or(excelFunctionNames[node.Name.Value], node.Name.Value) + "(" + strings.Join(arguments, ",") + ")", true
+	default:
+		return "", false
+	}
+}
+
+func excelOperand(node ExpressionNode, group bool) (string, bool) {
+	s, ok := excelFormula(node)
+	switch node.(type) {
+	case BinaryExpressionNode, UnaryExpressionNode:
+		if group {
+			return "(" + s + ")", ok
+		}
+	}
+	return s, ok
+}
+
+func isExponent(node ExpressionNode) bool {
+	binary, ok := node.(BinaryExpressionNode)
+	return ok && binary.Op.Type == TokenExponent
+}
+
+// excelExpression translates an Excel formula to an expression. Cell
+// references move up a row keeping their $ markers, AVERAGE becomes AVG and FACT(x) becomes (x)!.
+func excelExpression(formula string, maxColumn, maxRow int) (ExpressionNode, []CellIdentifier, error) {
+	tokens, err := tokenize(normalizeExpression(formula))
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		sb         strings.Builder
+		depth      int
+		factorials []int
+	)
+	for i, token := range tokens {
+		call := i+1 < len(tokens) && tokens[i+1].Type == TokenLeftParenthesis
+		switch token.Type {
+		case TokenIdentifier:
+			if call && token.Value == "FACT" {
+				factorials = append(factorials, depth+1)
+				continue
+			}
+			if call {
+				for name, excelName := range excelFunctionNames {
+					if token.Value == excelName {
+						token.Value = name
+					}
+				}
+			} else if parts := referencePattern.FindStringSubmatch(token.Value); parts != nil {
+				column, row, err := parseExcelCellID(parts[referencePattern.SubexpIndex("column")] + parts[referencePattern.SubexpIndex("row")])
+				if err != nil {
+					return nil, nil, err
+				}
+				token.Value = cellReferenceText(column, row,
+					parts[referencePattern.SubexpIndex("absoluteColumn")] != "",
+					parts[referencePattern.SubexpIndex("absoluteRow")] != "")
+			}
+		case TokenString:
+			token.Value = quoteText(token.Value)
+		case TokenExclamation:
+			return nil, nil, parseErrorf(token.Index, "references to other sheets are not supported")
+		case TokenLeftParenthesis:
+			depth++
+		case TokenRightParenthesis:
+			if n := len(factorials); n > 0 && factorials[n-1] == depth {
+				factorials = factorials[:n-1]
+				token.Value += "!"
+			}
+			depth--
+		}
+		sb.WriteString(token.Value)
+		sb.WriteByte(' ')
+	}
+	exp, refs, err := newExpression(sb.String(), maxColumn, maxRow)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fromExcelPrecedence(exp), refs, nil
+}
+
+// fromExcelPrecedence regroups the operands of ^ parsed with this
+// spreadsheet's precedence so the expression calculates what Excel would:
+// 2^3^2 is (2^3)^2 and -2^2 is (-2)^2.
+func fromExcelPrecedence(node ExpressionNode) ExpressionNode {
+	switch node := node.(type) {
+	case BinaryExpressionNode:
+		if node.Op.Type == TokenExponent {
+			return excelExponentChain(exponentOperands(node), node.Op)
+		}
+		node.Left = fromExcelPrecedence(node.Left)
+		node.Right = fromExcelPrecedence(node.Right)
+		return node
+	case UnaryExpressionNode:
+		var operators []Token
+		var operand ExpressionNode = node
+		for {
+			unary, ok := operand.(UnaryExpressionNode)
+			if !ok {
+				break
+			}
+			operators = append(operators, unary.Op)
+			operand = unary.Expression
+		}
+		wrap := func(operand ExpressionNode) ExpressionNode {
+			for i := len(operators) - 1; i >= 0; i-- {
+				operand = UnaryExpressionNode{Op: operators[i], Expression: operand}
+			}
+			return operand
+		}
+		if !isExponent(operand) {
+			return wrap(fromExcelPrecedence(operand))
+		}
+		binary := operand.(BinaryExpressionNode)
+		operands := exponentOperands(binary)
+		operands[0] = ParenNode{Node: wrap(operands[0])}
+		return excelExponentChain(operands, binary.Op)
+	case ParenNode:
+		node.Node = fromExcelPrecedence(node.Node)
+		return node
+	case FactorialNode:
+		node.Expression = fromExcelPrecedence(node.Expression)
+		return node
+	case FunctionNode:
+		arguments := make([]ExpressionNode, len(node.Arguments))
+		for i, argument := range node.Arguments {
+			arguments[i] = fromExcelPrecedence(argument)
+		}
+		node.Arguments = arguments
+		return node
+	default:
+		return node
+	}
+}
+
+// exponentOperands lists the operands of a chain of ^ that was parsed as
+// right associative.
+func exponentOperands(node BinaryExpressionNode) []ExpressionNode {
+	operands := []ExpressionNode{node.Left}
+	for {
+		right, ok := node.Right.(BinaryExpressionNode)
+		if !ok || right.Op.Type != TokenExponent {
+			return append(operands, node.Right)
+		}
+		operands = append(operands, right.Left)
+		node = right
+	}
+}
+
+// excelExponentChain raises the operands to a power from left to right
+// adding parentheses so the expression parses the same way again.
+func excelExponentChain(operands []ExpressionNode, op Token) ExpressionNode {
+	grouped := func(node ExpressionNode) ExpressionNode {
+		node = fromExcelPrecedence(node)
+		switch node.(type) {
+		case BinaryExpressionNode, UnaryExpressionNode:
+			return ParenNode{Node: node}
+		}
+		return node
+	}
+	result := grouped(operands[0])
+	for _, operand := range operands[1:] {
+		result = BinaryExpressionNode{Op: op, Left: grouped(result), Right: grouped(operand)}
+	}
+	return result
+}
@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func readFixtureXLSX(t *testing.T) Table {
+	t.Helper()
+	buf, err := os.ReadFile("testdata/table.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := ReadTableXLSX(bytes.NewReader(buf), int64(len(buf)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+func Test_readTableXLSX(t *testing.T) {
+	table := readFixtureXLSX(t)
+	if table.ColumnCount != 5 || table.RowCount != 6 {
+		t.Errorf("expected the worksheet dimension to set the table size got %d by %d", table.ColumnCount, table.RowCount)
+	}
+	for _, tt := range []struct {
+		ID, Expression, Value string
+	}{
+		{ID: "A0", Expression: `"Item"`, Value: "Item"},
+		{ID: "A3", Expression: `"fun"`, Value: "fun"},
+		{ID: "B3", Expression: "0.3", Value: "0.3"},
+		{ID: "B4", Expression: "SUM(B1:B3)", Value: "1251.05"},
+		{ID: "C0", Expression: "(3)!", Value: "6"},
+		{ID: "C1", Expression: "(-2) ^ 2", Value: "4"},
+		{ID: "C2", Expression: "(2 ^ 3) ^ 2", Value: "64"},
+		{ID: "C3", Expression: `B1 & " units"`, Value: "1000 units"},
+		{ID: "C4", Expression: `IF(B1 > 10, "big", "small")`, Value: "big"},
+		{ID: "D0", Expression: "6.2831853072", Value: "6.2831853072"},
+		{ID: "D1", Expression: "$B$1 * 2", Value: "2000"},
+		{ID: "D2", Expression: "TRUE", Value: "TRUE"},
+		{ID: "D3", Expression: "AVG(B1:B2)", Value: "625.375"},
+		{ID: "D4", Expression: "#N/A", Value: "#N/A"},
+	} {
+		t.Run(tt.ID, func(t *testing.T) {
+			column, row, err := ParseCellID(tt.ID, table.ColumnCount-1, table.RowCount-1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cell := table.Cell(column, row)
+			if cell.SavedExpression == nil {
+				t.Fatal("expected the cell to be set")
+			}
+			if got := cell.SavedExpression.String(); got != tt.Expression {
+				t.Errorf("expected expression %s got %s", tt.Expression, got)
+			}
+			if got := cell.String(); got != tt.Value {
+				t.Errorf("expected value %s got %s", tt.Value, got)
+			}
+		})
+	}
+}
+
+func TestTable_writeXLSX_roundTrip(t *testing.T) {
+	table := readFixtureXLSX(t)
+
+	var buf bytes.Buffer
+	if err := table.WriteXLSX(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadTableXLSX(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.ColumnCount != table.ColumnCount || loaded.RowCount != table.RowCount {
+		t.Errorf("expected a %d by %d table got %d by %d", table.ColumnCount, table.RowCount, loaded.ColumnCount, loaded.RowCount)
+	}
+	if loaded.CellCount() != table.CellCount() {
+		t.Errorf("expected %d cells got %d", table.CellCount(), loaded.CellCount())
+	}
+	for cell := range table.Cells() {
+		got := loaded.Cell(cell.Column, cell.Row)
+		if got.SavedExpression == nil {
+			t.Errorf("expected %s to be set", cell.Label())
+			continue
+		}
+		if got.SavedExpression.String() != cell.SavedExpression.String() {
+			t.Errorf("expected %s expression %s got %s", cell.Label(), cell.SavedExpression, got.SavedExpression)
+		}
+		if got.Value != cell.Value {
+			t.Errorf("expected %s value %s got %s", cell.Label(), cell.Value, got.Value)
+		}
+	}
+}
+
+func Test_readTableXLSX_partSize(t *testing.T) {
+	table := readFixtureXLSX(t)
+	var buf bytes.Buffer
+	if err := table.WriteXLSX(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := maxXLSXPartSize
+	maxXLSXPartSize = 64
+	t.Cleanup(func() { maxXLSXPartSize = previous })
+
+	_, err := ReadTableXLSX(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err == nil || !strings.Contains(err.Error(), "larger than 64 bytes") {
+		t.Errorf("expected the part to be too large got %v", err)
+	}
+}
+
+func Test_excelFormula(t *testing.T) {
+	for _, tt := range []struct {
+		Expression, Formula string
+	}{
+		{Expression: "A0 + B1 * 2", Formula: "A1+B2*2"},
+		{Expression: "AVG(A0:B9) & \"!\"", Formula: `AVERAGE(A1:B10)&"!"`},
+		{Expression: "-2 ^ 2", Formula: "-(2^2)"},
+		{Expression: "2 ^ 3 ^ 2", Formula: "2^(3^2)"},
+		{Expression: "2 ^ -1", Formula: "2^(-1)"},
+		{Expression: "(A0 + 1)!", Formula: "FACT(A1+1)"},
+		{Expression: "IF(A0 <> 1, TRUE, \"no\")", Formula: `IF(A1<>1,TRUE,"no")`},
+	} {
+		t.Run(tt.Expression, func(t *testing.T) {
+			exp, _, err := newExpression(tt.Expression, 9, 9)
+			if err != nil {
+				t.Fatal(err)
+			}
+			formula, ok := excelFormula(exp)
+			if !ok {
+				t.Fatal("expected the expression to have a formula")
+			}
+			if formula != tt.Formula {
+				t.Errorf("expected %s got %s", tt.Formula, formula)
+			}
+
+			back, _, err := excelExpression(formula, 9, 9)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := evaluate(&Table{ColumnCount: 10, RowCount: 10}, &Cell{}, newWalkState(0), exp)
+			got, _ := evaluate(&Table{ColumnCount: 10, RowCount: 10}, &Cell{}, newWalkState(0), back)
+			if got != want {
+				t.Errorf("expected the formula to calculate %s got %s from %s", want, got, back)
+			}
+		})
+	}
+
+	for _, expression := range []string{"ROW + 1", "OTHER!B3 + 1", "SUM(OTHER!A0:A1)"} {
+		exp, _, err := newExpression(expression, 9, 9)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if formula, ok := excelFormula(exp); ok {
+			t.Errorf("expected %s to not have a formula got %s", expression, formula)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+	"github.com/crhntr/sse"
+)
+
+const (
+	// clientHeader identifies the browser page that made a request so the
+	// page is not sent the cells it changed itself.
+	clientHeader = "Spreadsheet-Client"
+
+	cellsEvent sse.EventName = "cells"
+
+	// subscriberBuffer is the number of events a subscriber may fall behind
+	// before it is disconnected.
+	subscriberBuffer = 16
+)
+
+// subscriber is a browser page listening for changes of the sheet it shows.
+type subscriber struct {
+	client string
+	sheet  *engine.Table
+	events chan string
+}
+
+// subscribers holds the connected event streams. The zero value is ready to
+// use.
+type subscribers struct {
+	mut  sync.Mutex
+	list map[*subscriber]struct{}
+}
+
+func (subs *subscribers) add(client string, sheet *engine.Table) *subscriber {
+	subs.mut.Lock()
+	defer subs.mut.Unlock()
+	if subs.list == nil {
+		subs.list = make(map[*subscriber]struct{})
+	}
+	sub := &subscriber{client: client, sheet: sheet, events: make(chan string, subscriberBuffer)}
+	subs.list[sub] = struct{}{}
+	return sub
+}
+
+func (subs *subscribers) remove(sub *subscriber) {
+	subs.mut.Lock()
+	defer subs.mut.Unlock()
+	if _, ok := subs.list[sub]; ok {
+		delete(subs.list, sub)
+		close(sub.events)
+	}
+}
+
+// publish sends an event rendered by message to every subscriber except the
+// ones of the client that made the change. No event is sent when message
+// returns an empty string. A subscriber that does not keep up is removed;
+// its browser connects again and gets the events from then on.
+func (subs *subscribers) publish(client string, message func(sheet *engine.Table) string) {
+	subs.mut.Lock()
+	defer subs.mut.Unlock()
+	for sub := range subs.list {
+		if client != "" && sub.client == client {
+			continue
+		}
+		event := message(sub.sheet)
+		if event == "" {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(subs.list, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// getEvents streams the cells changed by other browsers as out of band
+// view-cell fragments.
+func (server *server) getEvents(res http.ResponseWriter, req *http.Request) {
+	server.mut.RLock()
+	sheet, err := server.sheet(req)
+	server.mut.RUnlock()
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	source, err := sse.NewEventSource(res, http.StatusOK)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sub := server.subscribers.add(req.URL.Query().Get("client"), sheet)
+	defer server.subscribers.remove(sub)
+	_ = http.NewResponseController(res).Flush()
+
+	ctx := req.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, open := <-sub.events:
+			if !open {
+				return
+			}
+			if _, err := source.Send(cellsEvent, event); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// publishCells sends the cells with the global identifiers to the browsers
+// showing their sheet. It must be called while holding the server lock.
+func (server *server) publishCells(req *http.Request, ids map[engine.CellIdentifier]bool) {
+	server.subscribers.publish(req.Header.Get(clientHeader), func(sheet *engine.Table) string {
+		if !server.hasSheet(sheet) {
+			return ""
+		}
+		var cells []*engine.Cell
+		for id := range ids {
+			if id.Sheet() == sheet.Key() {
+				cells = append(cells, sheet.Cell(id.Column(), id.Row()))
+			}
+		}
+		return server.renderCells(cells)
+	})
+}
+
+// publishSheets asks each browser to load the window of the sheet it is
+// showing again, for example after an upload replaced the workbook. Only
+// the rows and columns in view are rendered so the event does not grow with
+// the size of the sheet. It must be called while holding the server lock.
+func (server *server) publishSheets(req *http.Request) {
+	server.subscribers.publish(req.Header.Get(clientHeader), func(sheet *engine.Table) string {
+		if !server.hasSheet(sheet) {
+			return ""
+		}
+		var buf bytes.Buffer
+		if err := server.templates.ExecuteTemplate(&buf, "reload", true); err != nil {
+			return ""
+		}
+		return strings.ReplaceAll(buf.String(), "\n", "&#10;")
+	})
+}
+
+func (server *server) hasSheet(sheet *engine.Table) bool {
+	return slices.Contains(server.workbook.Sheets(), sheet)
+}
+
+// renderCells renders the cells as out of band swaps. An event's data can
+// not hold a blank line so newlines are written as character references.
+func (server *server) renderCells(cells []*engine.Cell) string {
+	if len(cells) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := server.executeCells(&buf, cells); err != nil {
+		return ""
+	}
+	return strings.ReplaceAll(buf.String(), "\n", "&#10;")
+}
+
+// executeCells writes each cell as an out of band swap.
+func (server *server) executeCells(w io.Writer, cells []*engine.Cell) error {
+	for _, cell := range cells {
+		if err := server.templates.ExecuteTemplate(w, "view-cell", cellView{Cell: cell, SwapOOB: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newClientID() string {
+	return rand.Text()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// subscribe connects to the event stream of the server as the client and
+// returns the data of each event it receives.
+func subscribe(t *testing.T, server *httptest.Server, client string) <-chan string {
+	t.Helper()
+	res, err := http.Get(server.URL + "/events?client=" + client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { closeAndIgnoreError(res.Body) })
+	if got := res.Header.Get("content-type"); !strings.HasPrefix(got, "text/event-stream") {
+		t.Fatalf("unexpected content type %q", got)
+	}
+	events := make(chan string)
+	go func() {
+		defer close(events)
+		var data strings.Builder
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				events <- data.String()
+				data.Reset()
+				continue
+			}
+			if value, ok := strings.CutPrefix(line, "data: "); ok {
+				data.WriteString(value)
+			}
+		}
+	}()
+	return events
+}
+
+func nextEvent(t *testing.T, events <-chan string) string {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("event stream closed")
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return ""
+	}
+}
+
+func patchAs(t *testing.T, server *httptest.Server, client string, cells url.Values) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPatch, server.URL+"/table", strings.NewReader(cells.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("content-type", "application/x-www-form-urlencoded")
+	req.Header.Set(clientHeader, client)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeAndIgnoreError(res.Body)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", res.StatusCode)
+	}
+}
+
+func TestServer_events(t *testing.T) {
+	s := newTestServer(3, 3)
+	server := httptest.NewServer(s.routes())
+	t.Cleanup(server.Close)
+
+	alice := subscribe(t, server, "alice")
+	bob := subscribe(t, server, "bob")
+
+	patchAs(t, server, "alice", url.Values{"cell-A0": {"2"}, "cell-A1": {"A0 * 3"}})
+
+	event := nextEvent(t, bob)
+	for _, want := range []string{
+		`id="cell-A0" hx-swap-oob="true"`,
+		`id="cell-A1" hx-swap-oob="true"`,
+		`>6</td>`,
+	} {
+		if !strings.Contains(event, want) {
+			t.Errorf("expected event to contain %s: %s", want, event)
+		}
+	}
+	if strings.Contains(event, "\n") {
+		t.Errorf("expected event data to be a single line: %q", event)
+	}
+
+	// alice made the edit so the first event she gets is the one for bob's
+	// edit of a cell that depends on hers
+	patchAs(t, server, "bob", url.Values{"cell-A0": {"5"}})
+	event = nextEvent(t, alice)
+	if !strings.Contains(event, `id="cell-A0"`) || !strings.Contains(event, `>15</td>`) {
+		t.Errorf("expected alice to get bob's edit: %s", event)
+	}
+	if strings.Contains(event, "cell-B0") {
+		t.Errorf("expected only changed cells: %s", event)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("table.json", "table.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = part.Write([]byte(`{"sheets": [{"name": "Sheet1", "columns": 3, "rows": 3, "cells": [{"id": "C2", "ex": "40 + 2"}]}]}`))
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/table.json", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("content-type", form.FormDataContentType())
+	req.Header.Set(clientHeader, "bob")
+	req.Header.Set("HX-Request", "true")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeAndIgnoreError(res.Body)
+
+	// instead of every cell the browser is asked to load its window again
+	event = nextEvent(t, alice)
+	if !strings.Contains(event, `<div id="reload" hx-swap-oob="true" hx-get="table" hx-trigger="load"`) {
+		t.Errorf("expected the table to be reloaded: %s", event)
+	}
+	if strings.Contains(event, "cell-") {
+		t.Errorf("expected no cells to be sent: %s", event)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+// loadWorkbook reads the workbook saved at path. When there is no file yet a
+// new workbook with a sheet of columns and rows is returned; it is written to
+// path on the first save.
+func loadWorkbook(path string, columns, rows int) (*engine.Workbook, error) {
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return engine.NewWorkbook(columns, rows), nil
+	} else if err != nil {
+		return nil, err
+	}
+	workbook, err := engine.ParseWorkbook(buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	return workbook, nil
+}
+
+// saveWorkbook writes the workbook to path. It writes a temporary file in the
+// same directory and renames it so path always holds a complete workbook.
+func saveWorkbook(path string, workbook *engine.Workbook) error {
+	buf, err := json.MarshalIndent(workbook, "", "\t")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+	if _, err := f.Write(buf); err != nil {
+		closeAndIgnoreError(f)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		closeAndIgnoreError(f)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// autosave saves the workbook to the file set with the -file flag after a
+// change was calculated. It must be called while holding the server lock.
+// The change is kept in memory when the file can not be written.
+func (server *server) autosave() {
+	if server.file == "" {
+		return
+	}
+	if err := saveWorkbook(server.file, server.workbook); err != nil {
+		log.Printf("failed to save %s: %s", server.file, err)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func Test_loadWorkbook(t *testing.T) {
+	t.Run("missing file", func(t *testing.T) {
+		workbook, err := loadWorkbook(filepath.Join(t.TempDir(), "table.json"), 3, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sheet := workbook.Sheets()[0]; sheet.ColumnCount != 3 || sheet.RowCount != 4 {
+			t.Errorf("unexpected size %dx%d", sheet.ColumnCount, sheet.RowCount)
+		}
+	})
+	t.Run("legacy table", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "table.json")
+		if err := os.WriteFile(path, []byte(`{"columns": 2, "rows": 2, "cells": [{"id": "A0", "ex": "1"}, {"id": "B1", "ex": "A0 + 1"}]}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		workbook, err := loadWorkbook(path, 10, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := workbook.Sheets()[0].Cell(1, 1).String(); got != "2" {
+			t.Errorf("expected B1 to be 2 but got %q", got)
+		}
+	})
+	t.Run("corrupt file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "table.json")
+		if err := os.WriteFile(path, []byte("{\n\t\"columns\": 2,\n\t\"rows\": 2,,\n}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := loadWorkbook(path, 10, 10)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		for _, want := range []string{path, "line 3 column"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected error to contain %q: %s", want, err)
+			}
+		}
+	})
+	t.Run("bad formula", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "table.json")
+		if err := os.WriteFile(path, []byte(`{"columns": 2, "rows": 2, "cells": [{"id": "A0", "ex": "1 +"}]}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadWorkbook(path, 10, 10); err == nil || !strings.Contains(err.Error(), path) {
+			t.Errorf("expected an error naming the file: %v", err)
+		}
+	})
+}
+
+func Test_saveWorkbook(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "table.json")
+	workbook := engine.NewWorkbook(2, 2)
+	if err := workbook.Sheets()[0].SetExpression(0, 0, "7"); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if err := saveWorkbook(path, workbook); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "table.json" {
+		t.Errorf("expected only the saved file to be left in the directory: %v", entries)
+	}
+	loaded, err := loadWorkbook(path, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Sheets()[0].Cell(0, 0).String(); got != "7" {
+		t.Errorf("expected A0 to be 7 but got %q", got)
+	}
+}
+
+func TestServer_autosave(t *testing.T) {
+	s := newTestServer(3, 3)
+	s.file = filepath.Join(t.TempDir(), "table.json")
+
+	patchCells(t, s, map[string]string{"A0": "2", "A1": "A0 * 21"})
+
+	loaded, err := loadWorkbook(s.file, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Sheets()[0].Cell(0, 1).String(); got != "42" {
+		t.Errorf("expected the saved A1 to be 42 but got %q", got)
+	}
+
+	serveForm(t, s, http.MethodPost, "/undo", nil)
+
+	loaded, err = loadWorkbook(s.file, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Sheets()[0].CellCount(); got != 0 {
+		t.Errorf("expected the undone edit to be saved but got %d cells", got)
+	}
+}
+
+func TestServer_postTableJSON_corrupt(t *testing.T) {
+	s := newTestServer(3, 3)
+	patchCells(t, s, map[string]string{"A0": "1"})
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("table.json", "table.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = part.Write([]byte(`{"columns": 3, "rows": `))
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/table.json", &body)
+	req.Header.Set("content-type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d but got %d", http.StatusBadRequest, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "failed to read table.json") {
+		t.Errorf("expected a clear error: %s", rec.Body.String())
+	}
+	if got := s.workbook.Sheets()[0].Cell(0, 0).String(); got != "1" {
+		t.Errorf("expected the workbook to be unchanged but A0 is %q", got)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+// postFill copies the expression of the source cell into every cell of the
+// target range, like dragging the fill handle of a cell down or right.
+func (server *server) postFill(res http.ResponseWriter, req *http.Request) {
+	server.mut.Lock()
+	defer server.mut.Unlock()
+
+	if err := req.ParseForm(); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sheet, err := server.sheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	maxColumn, maxRow := sheet.ColumnCount-1, sheet.RowCount-1
+	sourceColumn, sourceRow, err := engine.ParseCellID(req.Form.Get("source"), maxColumn, maxRow)
+	if err != nil {
+		http.Error(res, fmt.Sprintf("failed to parse source: %s", err), http.StatusBadRequest)
+		return
+	}
+	target, err := engine.ParseRange(req.Form.Get("target"), maxColumn, maxRow)
+	if err != nil {
+		http.Error(res, fmt.Sprintf("failed to parse target: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	edit, affected, err := server.workbook.Fill(sheet, sourceColumn, sourceRow, target)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	server.commitCells(req, edit, affected)
+
+	server.renderTable(res, req, sheet)
+}
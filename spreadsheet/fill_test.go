@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func TestServer_postFill(t *testing.T) {
+	s := newTestServer(3, 4)
+	patchCells(t, s, map[string]string{"A0": "1", "A1": "2", "A2": "3", "A3": "4", "C0": "10", "B0": "A0 * $C$0"})
+
+	rec := serveForm(t, s, http.MethodPost, "/fill", url.Values{"source": {"B0"}, "target": {"B0:B3"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	sheet := s.workbook.Sheets()[0]
+	for row, want := range []string{"10", "20", "30", "40"} {
+		if got := sheet.Cell(1, row).String(); got != want {
+			t.Errorf("expected B%d to be %s got %s", row, want, got)
+		}
+	}
+	if got := sheet.Cell(1, 3).SavedExpression.String(); got != "A3 * $C$0" {
+		t.Errorf("unexpected filled expression %s", got)
+	}
+
+	// the absolute reference to C0 does not move so C0 references itself
+	rec = serveForm(t, s, http.MethodPost, "/fill", url.Values{"source": {"B1"}, "target": {"C0"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := sheet.Cell(2, 0).SavedExpression.String(); got != "B0 * $C$0" {
+		t.Errorf("unexpected filled expression %s", got)
+	}
+	if got := sheet.Cell(2, 0).ErrorKind(); got != string(engine.ErrorCycle) {
+		t.Errorf("expected C0 to reference itself got %q", got)
+	}
+
+	// filling up moves the reference to A0 above the first row
+	patchCells(t, s, map[string]string{"A0": "2", "A1": "A0 + 1"})
+	rec = serveForm(t, s, http.MethodPost, "/fill", url.Values{"source": {"A1"}, "target": {"A0"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected an out of bounds reference to not fail the request got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := sheet.Cell(0, 0); got.SavedExpression.String() != "#REF! + 1" || got.ErrorKind() != string(engine.ErrorReference) {
+		t.Errorf("expected A0 to be a reference error got %s = %s", got.SavedExpression, got.Value)
+	}
+
+	// a fill is undone in one step
+	serveForm(t, s, http.MethodPost, "/undo", nil)
+	if got := sheet.Cell(0, 0).String(); got != "2" {
+		t.Errorf("expected undo to restore A0 got %s", got)
+	}
+
+	for _, form := range []url.Values{
+		{"source": {"Z0"}, "target": {"A0"}},
+		{"source": {"A0"}, "target": {"A0:A9"}},
+		{"source": {"A0"}, "target": {""}},
+	} {
+		if rec := serveForm(t, s, http.MethodPost, "/fill", form); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected %v to be a bad request got %d", form, rec.Code)
+		}
+	}
+
+	// C1 gets A3 + 1 but #REF! + 1 in C2 is too long so no cell changes
+	patchCells(t, s, map[string]string{"C0": "A2 + 1"})
+	if err := engine.SetLimits(engine.Limits{FormulaLength: 7, NestingDepth: 64, Steps: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = engine.SetLimits(engine.DefaultLimits) })
+	rec = serveForm(t, s, http.MethodPost, "/fill", url.Values{"source": {"C0"}, "target": {"C0:C3"}})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "C2") {
+		t.Errorf("expected the fill to be a bad request got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := sheet.Cell(2, 1).ExpressionText(); got != "" {
+		t.Errorf("expected C1 to be unchanged got %s", got)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+// selectedCell is the cell being edited and the element ids of the cells
+// of its sheet it references and that reference it, they are highlighted.
+type selectedCell struct {
+	Cell                   cellView
+	Precedents, Dependents []string
+}
+
+func (server *server) selectCell(sheet *engine.Table, cell *engine.Cell) selectedCell {
+	selected := selectedCell{Cell: cellView{Cell: cell}}
+	for _, d := range []engine.Direction{engine.Precedents, engine.Dependents} {
+		var ids []string
+		for _, c := range server.workbook.Graph(sheet, cell.Column, cell.Row, d).Cells {
+			if id := c.Identifier(); id.Sheet() == sheet.Key() {
+				ids = append(ids, cellView{Cell: sheet.Cell(id.Column(), id.Row())}.ID())
+			}
+		}
+		if d == engine.Precedents {
+			selected.Precedents = ids
+		} else {
+			selected.Dependents = ids
+		}
+	}
+	return selected
+}
+
+func (server *server) getPrecedents(res http.ResponseWriter, req *http.Request) {
+	server.getCellGraph(res, req, engine.Precedents, false)
+}
+
+func (server *server) getPrecedentsJSON(res http.ResponseWriter, req *http.Request) {
+	server.getCellGraph(res, req, engine.Precedents, true)
+}
+
+func (server *server) getDependents(res http.ResponseWriter, req *http.Request) {
+	server.getCellGraph(res, req, engine.Dependents, false)
+}
+
+func (server *server) getDependentsJSON(res http.ResponseWriter, req *http.Request) {
+	server.getCellGraph(res, req, engine.Dependents, true)
+}
+
+func (server *server) getCellGraph(res http.ResponseWriter, req *http.Request, d engine.Direction, asJSON bool) {
+	server.mut.RLock()
+	defer server.mut.RUnlock()
+
+	sheet, err := server.sheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	column, row, err := engine.ParseCellID(req.PathValue("id"), sheet.ColumnCount-1, sheet.RowCount-1)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	graph := server.workbook.Graph(sheet, column, row, d)
+	if !asJSON {
+		server.render(res, req, "cell-graph", http.StatusOK, graph)
+		return
+	}
+	buf, err := json.MarshalIndent(graph, "", "\t")
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h := res.Header()
+	h.Set("content-type", "application/json")
+	h.Set("content-length", strconv.Itoa(len(buf)))
+	res.WriteHeader(http.StatusOK)
+	_, _ = res.Write(buf)
+}
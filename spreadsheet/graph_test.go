@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func getGraph(t *testing.T, s *server, path string) engine.CellGraph {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("content-type"); got != "application/json" {
+		t.Errorf("unexpected content type %s", got)
+	}
+	var graph engine.CellGraph
+	if err := json.Unmarshal(rec.Body.Bytes(), &graph); err != nil {
+		t.Fatal(err)
+	}
+	return graph
+}
+
+func graphIDs(graph engine.CellGraph) []string {
+	ids := make([]string, 0, len(graph.Cells))
+	for _, cell := range graph.Cells {
+		ids = append(ids, cell.ID)
+	}
+	return ids
+}
+
+func TestServer_cellGraph(t *testing.T) {
+	s := newTestServer(3, 3)
+	if _, _, err := s.workbook.AddSheet("Other", 2, 2); err != nil {
+		t.Fatal(err)
+	}
+	if rec := serveForm(t, s, http.MethodPost, "/names", url.Values{"name": {"RATE"}, "reference": {"C2"}}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	patchCells(t, s, map[string]string{"A0": "1", "A1": "A0 + 1", "A2": "A1 * RATE", "B0": "SUM(A0:A1)", "C2": "2"})
+	rec := serveForm(t, s, http.MethodPatch, "/sheets/Other/table", url.Values{"cell-A0": {"SHEET1!A2 + 1"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	graph := getGraph(t, s, "/cell/A2/precedents.json")
+	if graph.Cell != "A2" || graph.Direction != engine.Precedents {
+		t.Errorf("unexpected graph %+v", graph)
+	}
+	if got, want := graphIDs(graph), []string{"A1", "C2", "A0"}; !slices.Equal(got, want) {
+		t.Errorf("expected the Precedents %v got %v", want, got)
+	}
+	if got := graph.Cells[0]; got.Depth != 1 || got.Expression != "A0 + 1" || got.Value != "2" || !slices.Equal(got.Edges, []string{"A0"}) {
+		t.Errorf("unexpected precedent %+v", got)
+	}
+	if got := graph.Cells[2].Depth; got != 2 {
+		t.Errorf("expected A0 to be 2 references away got %d", got)
+	}
+
+	graph = getGraph(t, s, "/cell/A0/dependents.json")
+	if got, want := graphIDs(graph), []string{"B0", "A1", "A2", "OTHER!A0"}; !slices.Equal(got, want) {
+		t.Errorf("expected the Dependents %v got %v", want, got)
+	}
+
+	// cells of the sheet in the path are written without the sheet name
+	graph = getGraph(t, s, "/sheets/Other/cell/A0/precedents.json")
+	if got, want := graphIDs(graph), []string{"SHEET1!A2", "SHEET1!A1", "SHEET1!C2", "SHEET1!A0"}; !slices.Equal(got, want) {
+		t.Errorf("expected the Precedents %v got %v", want, got)
+	}
+
+	rec = httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cell/A2/precedents", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, want := range []string{`<section id="inspector">`, "Precedents of A2", "<code>A1</code>", "(uses A0)"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("expected the fragment to contain %q: %s", want, rec.Body.String())
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cell/C0/dependents", nil))
+	if !strings.Contains(rec.Body.String(), "<p>None</p>") {
+		t.Errorf("expected a cell without Dependents to show none: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cell/Z9/precedents.json", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a bad request got %d", rec.Code)
+	}
+}
+
+func TestServer_getCellEdit_highlight(t *testing.T) {
+	s := newTestServer(3, 3)
+	patchCells(t, s, map[string]string{"A0": "1", "A1": "A0 + 1", "A2": "A1 * 2", "B0": "SUM(A0:A1)"})
+
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cell/A1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<input type="text" name="cell-A1"`,
+		`<style id="highlight" hx-swap-oob="true">`,
+		`#cell-A0 { background: lightgreen; }`,
+		`#cell-B0, #cell-A2 { background: lightsalmon; }`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the response to contain %q: %s", want, body)
+		}
+	}
+}
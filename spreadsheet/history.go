@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+const defaultHistoryDepth = 100
+
+// history holds the changes that can be undone and redone. Only the last
+// depth changes are kept.
+type history struct {
+	depth int
+	undo,
+	redo []*engine.Change
+}
+
+// record adds a change made by an edit. An edit discards the changes that
+// were undone.
+func (h *history) record(c *engine.Change) {
+	if c.Empty() || h.depth < 1 {
+		return
+	}
+	h.undo = append(h.undo, c)
+	if len(h.undo) > h.depth {
+		h.undo = slices.Delete(h.undo, 0, len(h.undo)-h.depth)
+	}
+	h.redo = nil
+}
+
+func (server *server) postUndo(res http.ResponseWriter, req *http.Request) {
+	server.mut.Lock()
+	defer server.mut.Unlock()
+
+	var c *engine.Change
+	if n := len(server.history.undo); n > 0 {
+		c = server.history.undo[n-1]
+		server.history.undo = server.history.undo[:n-1]
+		server.history.redo = append(server.history.redo, c)
+		server.workbook.Apply(c, true)
+		server.autosave()
+	}
+	server.renderChange(res, req, c)
+}
+
+func (server *server) postRedo(res http.ResponseWriter, req *http.Request) {
+	server.mut.Lock()
+	defer server.mut.Unlock()
+
+	var c *engine.Change
+	if n := len(server.history.redo); n > 0 {
+		c = server.history.redo[n-1]
+		server.history.redo = server.history.redo[:n-1]
+		server.history.undo = append(server.history.undo, c)
+		server.workbook.Apply(c, false)
+		server.autosave()
+	}
+	server.renderChange(res, req, c)
+}
+
+// renderChange renders the table of the sheet after an undo or redo. When
+// the list of sheets changed the whole page is loaded again.
+func (server *server) renderChange(res http.ResponseWriter, req *http.Request, c *engine.Change) {
+	if c.SheetsChanged() {
+		redirect(res, req, "/")
+		return
+	}
+	sheet, err := server.sheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	server.renderTable(res, req, sheet)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func TestServer_undoRedo(t *testing.T) {
+	s := newTestServer(3, 3)
+	sheet := s.workbook.Sheets()[0]
+	patchCells(t, s, map[string]string{"A0": "1", "A1": "A0 + 1"})
+	patchCells(t, s, map[string]string{"A0": "5", "B0": `"note"`})
+
+	rec := serveForm(t, s, http.MethodPost, "/undo", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `id="table"`) {
+		t.Fatalf("expected the table to be rendered got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := sheet.Cell(0, 0).Value; got != engine.NewNumber(1) {
+		t.Errorf("expected A0 to be 1 after undo got %s", got)
+	}
+	if got := sheet.Cell(0, 1).Value; got != engine.NewNumber(2) {
+		t.Errorf("expected the dependent A1 to be 2 after undo got %s", got)
+	}
+	if sheet.Cell(1, 0).SavedExpression != nil {
+		t.Errorf("expected B0 to be removed after undo")
+	}
+
+	serveForm(t, s, http.MethodPost, "/redo", nil)
+	if got := sheet.Cell(0, 1).Value; got != engine.NewNumber(6) {
+		t.Errorf("expected A1 to be 6 after redo got %s", got)
+	}
+	if got := sheet.Cell(1, 0).String(); got != "note" {
+		t.Errorf("expected B0 to be restored after redo got %q", got)
+	}
+
+	serveForm(t, s, http.MethodPost, "/undo", nil)
+	patchCells(t, s, map[string]string{"A0": "9"})
+	serveForm(t, s, http.MethodPost, "/redo", nil)
+	if got := sheet.Cell(0, 0).Value; got != engine.NewNumber(9) {
+		t.Errorf("expected an edit after undo to discard the redo history got %s", got)
+	}
+
+	serveForm(t, s, http.MethodPost, "/undo", nil)
+	serveForm(t, s, http.MethodPost, "/undo", nil)
+	if sheet.Cell(0, 0).SavedExpression != nil || sheet.Cell(0, 1).SavedExpression != nil {
+		t.Errorf("expected undoing every edit to leave an empty table")
+	}
+	if rec := serveForm(t, s, http.MethodPost, "/undo", nil); rec.Code != http.StatusOK {
+		t.Errorf("expected undo without history to render the table got %d", rec.Code)
+	}
+}
+
+func TestServer_undo_depth(t *testing.T) {
+	s := newTestServer(3, 3)
+	s.history.depth = 2
+	for _, value := range []string{"1", "2", "3", "4"} {
+		patchCells(t, s, map[string]string{"A0": value})
+	}
+	for range 4 {
+		serveForm(t, s, http.MethodPost, "/undo", nil)
+	}
+	if got := s.workbook.Sheets()[0].Cell(0, 0).Value; got != engine.NewNumber(2) {
+		t.Errorf("expected only the last two edits to be undone got %s", got)
+	}
+}
+
+func TestServer_undo_upload(t *testing.T) {
+	s := newTestServer(2, 2)
+	if _, _, err := s.workbook.AddSheet("Data", 2, 2); err != nil {
+		t.Fatal(err)
+	}
+	patchCells(t, s, map[string]string{"A0": "1", "B1": "DATA!A0 + A0"})
+
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/sheets/Data/table.csv", strings.NewReader("10,20,30\n")))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	data := s.workbook.Sheet("Data")
+	if data.ColumnCount != 3 || data.RowCount != 1 {
+		t.Fatalf("expected the upload to resize the sheet got %d by %d", data.ColumnCount, data.RowCount)
+	}
+	if got := s.workbook.Sheets()[0].Cell(1, 1).Value; got != engine.NewNumber(11) {
+		t.Errorf("expected B1 to use the uploaded value got %s", got)
+	}
+
+	serveForm(t, s, http.MethodPost, "/sheets/Data/undo", nil)
+	if data.ColumnCount != 2 || data.RowCount != 2 || data.CellCount() != 0 {
+		t.Errorf("expected undo to restore the empty 2 by 2 sheet got %d by %d with %d cells", data.ColumnCount, data.RowCount, data.CellCount())
+	}
+	if got := s.workbook.Sheets()[0].Cell(1, 1).Value; got != engine.NewNumber(1) {
+		t.Errorf("expected B1 to be 1 after undo got %s", got)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	w, err := form.CreateFormFile("table.json", "table.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte(`{"sheets":[{"name":"Sheet1","columns":2,"rows":2,"cells":[{"id":"A0","ex":"2"}]},{"name":"Extra","columns":1,"rows":1,"cells":[]}]}`))
+	_ = form.Close()
+	req := httptest.NewRequest(http.MethodPost, "/table.json", &body)
+	req.Header.Set("content-type", form.FormDataContentType())
+	rec = httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, req)
+	if len(s.workbook.Sheets()) != 2 || s.workbook.Sheet("Extra") == nil {
+		t.Fatalf("expected the uploaded sheets to replace the workbook")
+	}
+
+	rec = serveForm(t, s, http.MethodPost, "/undo", nil)
+	if rec.Code != http.StatusSeeOther {
+		t.Errorf("expected undoing a change to the sheets to reload the page got %d", rec.Code)
+	}
+	if s.workbook.Sheet("Data") != data || s.workbook.Sheet("Extra") != nil {
+		t.Errorf("expected undo to restore the sheets")
+	}
+	if got := s.workbook.Sheets()[0].Cell(0, 0).Value; got != engine.NewNumber(1) {
+		t.Errorf("expected A0 to be 1 after undo got %s", got)
+	}
+}
+
+func TestServer_undo_sheets(t *testing.T) {
+	s := newTestServer(2, 2)
+	first := s.workbook.Sheets()[0]
+	serveForm(t, s, http.MethodPost, "/sheets", url.Values{"name": {"Data"}})
+	serveForm(t, s, http.MethodPatch, "/sheets/Data/table", url.Values{"cell-A0": {"5"}})
+	patchCells(t, s, map[string]string{"A0": "DATA!A0 + 1"})
+	serveForm(t, s, http.MethodPatch, "/sheets/Data", url.Values{"name": {"Inputs"}})
+	serveForm(t, s, http.MethodDelete, "/sheets/Inputs", nil)
+	if got := first.Cell(0, 0).ErrorKind(); got != string(engine.ErrorReference) {
+		t.Fatalf("expected a reference to the deleted sheet to be %s got %q", engine.ErrorReference, got)
+	}
+
+	rec := serveForm(t, s, http.MethodPost, "/undo", nil)
+	if rec.Code != http.StatusSeeOther {
+		t.Errorf("expected undoing a sheet change to load the page again got %d", rec.Code)
+	}
+	if s.workbook.Sheet("Inputs") == nil || first.Cell(0, 0).Value != engine.NewNumber(6) {
+		t.Errorf("expected undo to restore the deleted sheet got %s", first.Cell(0, 0).Value)
+	}
+
+	serveForm(t, s, http.MethodPost, "/undo", nil)
+	if s.workbook.Sheet("Data") == nil || s.workbook.Sheet("Inputs") != nil {
+		t.Errorf("expected undo to restore the name of the sheet")
+	}
+	if got := first.Cell(0, 0).SavedExpression.String(); got != "DATA!A0 + 1" {
+		t.Errorf("expected undo to restore the reference got %s", got)
+	}
+
+	// the edits of the sheet are undone before the sheet is removed
+	serveForm(t, s, http.MethodPost, "/undo", nil)
+	serveForm(t, s, http.MethodPost, "/sheets/Data/undo", nil)
+	serveForm(t, s, http.MethodPost, "/undo", nil)
+	if len(s.workbook.Sheets()) != 1 {
+		t.Errorf("expected undo to remove the added sheet")
+	}
+
+	serveForm(t, s, http.MethodPost, "/redo", nil)
+	if data := s.workbook.Sheet("Data"); data == nil || data.CellCount() != 0 {
+		t.Errorf("expected redo to add the empty sheet again")
+	}
+}
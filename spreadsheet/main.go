@@ -5,31 +5,54 @@ import (
 	"cmp"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
+	"maps"
 	"net/http"
 	"os"
-	"regexp"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
-	"unicode"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
 )
 
 //go:embed index.html.template
 var indexHTMLTemplate string
 
 func main() {
-	table := Table{ColumnCount: 10, RowCount: 10}
-	flag.IntVar(&table.ColumnCount, "columns", table.ColumnCount, "the number of table columns")
-	flag.IntVar(&table.RowCount, "rows", table.RowCount, "the number of table rows")
+	columns, rows := 10, 10
+	flag.IntVar(&columns, "columns", columns, "the number of table columns")
+	flag.IntVar(&rows, "rows", rows, "the number of table rows")
+	historyDepth := flag.Int("history", defaultHistoryDepth, "the number of edits that can be undone")
+	file := flag.String("file", "", "the JSON file the workbook is loaded from and saved to after each change")
+	limits := engine.DefaultLimits
+	flag.IntVar(&limits.FormulaLength, "max-formula-length", limits.FormulaLength, "the maximum number of characters of a formula")
+	flag.IntVar(&limits.NestingDepth, "max-nesting-depth", limits.NestingDepth, "the maximum number of nested parentheses and function calls in a formula")
+	flag.IntVar(&limits.Steps, "max-steps", limits.Steps, "the maximum number of expressions evaluated in one recalculation")
 	flag.Parse()
+	if err := engine.SetLimits(limits); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	workbook := engine.NewWorkbook(columns, rows)
+	if *file != "" {
+		var err error
+		workbook, err = loadWorkbook(*file, columns, rows)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
 	s := server{
-		table:     table,
+		workbook:  workbook,
+		file:      *file,
+		history:   history{depth: *historyDepth},
 		templates: template.Must(template.New("index.html.template").Parse(indexHTMLTemplate)),
 	}
 	log.Println("starting server")
@@ -37,8 +60,15 @@ func main() {
 }
 
 type server struct {
-	table Table
-	mut   sync.RWMutex
+	workbook *engine.Workbook
+	history  history
+	mut      sync.RWMutex
+
+	subscribers subscribers
+
+	// file is where the workbook is saved after each change. When it is
+	// empty the workbook is only kept in memory.
+	file string
 
 	templates *template.Template
 }
@@ -49,8 +79,39 @@ func (server *server) routes() *http.ServeMux {
 	mux.HandleFunc("GET /", server.index)
 	mux.HandleFunc("GET /table.json", server.getTableJSON)
 	mux.HandleFunc("POST /table.json", server.postTableJSON)
-	mux.HandleFunc("GET /cell/{id}", server.getCellEdit)
-	mux.HandleFunc("PATCH /table", server.patchTable)
+	mux.HandleFunc("POST /sheets", server.postSheet)
+	mux.HandleFunc("PATCH /sheets/{sheet}", server.patchSheet)
+	mux.HandleFunc("DELETE /sheets/{sheet}", server.deleteSheet)
+	mux.HandleFunc("GET /sheets/{sheet}/{$}", server.index)
+	mux.HandleFunc("POST /names", server.postName)
+	mux.HandleFunc("PATCH /names/{name}", server.patchName)
+	mux.HandleFunc("DELETE /names/{name}", server.deleteName)
+	mux.HandleFunc("POST /iteration", server.postIteration)
+
+	// the routes without the sheet prefix use the first sheet
+	for _, prefix := range []string{"", "/sheets/{sheet}"} {
+		mux.HandleFunc("GET "+prefix+"/table.csv", server.getTableCSV)
+		mux.HandleFunc("POST "+prefix+"/table.csv", server.postTableCSV)
+		mux.HandleFunc("GET "+prefix+"/table.xlsx", server.getTableXLSX)
+		mux.HandleFunc("POST "+prefix+"/table.xlsx", server.postTableXLSX)
+		mux.HandleFunc("GET "+prefix+"/cell/{id}", server.getCellEdit)
+		mux.HandleFunc("PATCH "+prefix+"/cell/{id}", server.patchCell)
+		mux.HandleFunc("GET "+prefix+"/cell/{id}/precedents", server.getPrecedents)
+		mux.HandleFunc("GET "+prefix+"/cell/{id}/precedents.json", server.getPrecedentsJSON)
+		mux.HandleFunc("GET "+prefix+"/cell/{id}/dependents", server.getDependents)
+		mux.HandleFunc("GET "+prefix+"/cell/{id}/dependents.json", server.getDependentsJSON)
+		mux.HandleFunc("GET "+prefix+"/table", server.getTable)
+		mux.HandleFunc("PATCH "+prefix+"/table", server.patchTable)
+		mux.HandleFunc("GET "+prefix+"/rows", server.getRows)
+		mux.HandleFunc("POST "+prefix+"/fill", server.postFill)
+		mux.HandleFunc("POST "+prefix+"/rows/{index}", server.postRow)
+		mux.HandleFunc("DELETE "+prefix+"/rows/{index}", server.deleteRow)
+		mux.HandleFunc("POST "+prefix+"/columns/{index}", server.postColumn)
+		mux.HandleFunc("DELETE "+prefix+"/columns/{index}", server.deleteColumn)
+		mux.HandleFunc("POST "+prefix+"/undo", server.postUndo)
+		mux.HandleFunc("POST "+prefix+"/redo", server.postRedo)
+		mux.HandleFunc("GET "+prefix+"/events", server.getEvents)
+	}
 
 	return mux
 }
@@ -67,40 +128,52 @@ func (server *server) render(res http.ResponseWriter, _ *http.Request, name stri
 	_, _ = res.Write(buf.Bytes())
 }
 
+// sheetPage is the data of the index page. The table is the sheet being
+// shown. Client identifies the page in requests and event streams.
+type sheetPage struct {
+	sheetView
+	Sheets    []*engine.Table
+	Names     []engine.Name
+	Iteration engine.Iteration
+	Client    string
+}
+
 func (server *server) index(res http.ResponseWriter, req *http.Request) {
 	server.mut.RLock()
 	defer server.mut.RUnlock()
-	server.render(res, req, "index.html.template", http.StatusOK, &server.table)
+
+	sheet, err := server.sheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	server.render(res, req, "index.html.template", http.StatusOK, sheetPage{sheetView: server.view(req, sheet), Sheets: server.workbook.Sheets(), Names: server.workbook.Names(), Iteration: server.workbook.Iteration(), Client: newClientID()})
 }
 
 func (server *server) getCellEdit(res http.ResponseWriter, req *http.Request) {
 	server.mut.RLock()
 	defer server.mut.RUnlock()
 
-	column, row, err := parseCellID(req.PathValue("id"), server.table.ColumnCount-1, server.table.RowCount-1)
+	sheet, err := server.sheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	column, row, err := engine.ParseCellID(req.PathValue("id"), sheet.ColumnCount-1, sheet.RowCount-1)
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	cell := server.table.Cell(column, row)
-	server.render(res, req, "edit-cell", http.StatusOK, cell)
+	cell := sheet.Cell(column, row)
+	server.render(res, req, "selected-cell", http.StatusOK, server.selectCell(sheet, cell))
 }
 
 func (server *server) getTableJSON(res http.ResponseWriter, _ *http.Request) {
 	server.mut.RLock()
 	defer server.mut.RUnlock()
 
-	filtered := server.table.Cells[:0]
-	for _, cell := range server.table.Cells {
-		if cell.SavedExpression == nil || cell.Expression == nil {
-			continue
-		}
-		filtered = append(filtered, cell)
-	}
-	server.table.Cells = filtered
-
-	buf, err := json.MarshalIndent(server.table, "", "\t")
+	buf, err := json.MarshalIndent(server.workbook, "", "\t")
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusBadRequest)
 		return
@@ -133,19 +206,57 @@ func (server *server) postTableJSON(res http.ResponseWriter, req *http.Request)
 		http.Error(res, err.Error(), http.StatusBadRequest)
 		return
 	}
-	var table Table
-	if err = json.Unmarshal(tableJSON, &table); err != nil {
-		log.Fatal(err)
-	}
-	if err := table.calculateValues(); err != nil {
-		http.Error(res, err.Error(), http.StatusBadRequest)
+	workbook, err := engine.ParseWorkbook(tableJSON)
+	if err != nil {
+		http.Error(res, "failed to read table.json: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	server.mut.Lock()
 	defer server.mut.Unlock()
-	server.table = table
+	server.history.record(server.workbook.Replace(workbook))
+	server.autosave()
+	server.publishSheets(req)
+
+	// the sheets may have changed so the whole page is loaded again
+	redirect(res, req, "/")
+}
+
+// maxUploadSize is the most bytes read from the body of an upload request.
+const maxUploadSize = 10 << 20
+
+// uploadedFile returns the part of a multipart form with the form name or
+// the request body when the request is not a multipart form. The form is
+// read as a stream so the size of the file is not limited by the memory
+// limit of a parsed multipart form; reading more than maxUploadSize bytes
+// of the body fails with an *http.MaxBytesError.
+func uploadedFile(res http.ResponseWriter, req *http.Request, name string) (io.Reader, error) {
+	req.Body = http.MaxBytesReader(res, req.Body, maxUploadSize)
+	reader, err := req.MultipartReader()
+	if errors.Is(err, http.ErrNotMultipart) {
+		return req.Body, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("expected %s file", name)
+		} else if err != nil {
+			return nil, err
+		}
+		if part.FormName() == name {
+			return part, nil
+		}
+	}
+}
 
-	server.render(res, req, "table", http.StatusOK, &server.table)
+// uploadErrorStatus returns the status of a failure to read an upload.
+func uploadErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
 }
 
 func closeAndIgnoreError(c io.Closer) {
@@ -160,698 +271,110 @@ func (server *server) patchTable(res http.ResponseWriter, req *http.Request) {
 		http.Error(res, err.Error(), http.StatusBadRequest)
 		return
 	}
+	sheet, err := server.sheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
 
+	var edits []engine.CellEdit
 	for key, value := range req.Form {
-		if !strings.HasPrefix(key, "cell-") {
+		id, ok := strings.CutPrefix(key, cellIDPrefix)
+		if !ok {
 			continue
 		}
-		column, row, err := parseCellID(key, server.table.ColumnCount-1, server.table.RowCount-1)
+		column, row, err := engine.ParseCellID(id, sheet.ColumnCount-1, sheet.RowCount-1)
 		if err != nil {
 			http.Error(res, err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		cell := server.cellPointer(column, row)
-		cell.Error = ""
-		cell.input = normalizeExpression(value[0])
-
-		var (
-			expression ExpressionNode
-			refs       []CellIdentifier
-		)
-		if cell.input != "" {
-			expression, refs, err = newExpression(cell.input, server.table.ColumnCount-1, server.table.RowCount-1)
-			if err != nil {
-				cell.Error = err.Error()
-				continue
-			}
-			cell.input = expression.String()
-		}
-		cell.Expression = expression
-		cell.References = refs
-	}
-
-	err := server.table.calculateValues()
-	if err != nil {
-		server.render(res, req, "table", http.StatusOK, &server.table)
-		return
-	}
-
-	server.render(res, req, "table", http.StatusOK, &server.table)
-}
-
-func (server *server) cellPointer(column, row int) *Cell {
-	var cell *Cell
-	index := slices.IndexFunc(server.table.Cells, func(cell Cell) bool {
-		return cell.Row == row && cell.Column == column
-	})
-	if index >= 0 {
-		cell = &server.table.Cells[index]
-	} else {
-		server.table.Cells = append(server.table.Cells, Cell{
-			Row:    row,
-			Column: column,
-		})
-		cell = &server.table.Cells[len(server.table.Cells)-1]
-	}
-	return cell
-}
-
-func normalizeExpression(in string) string {
-	return strings.TrimSpace(strings.ToUpper(in))
-}
-
-type Column struct {
-	Number int
-}
-
-func (column Column) Label() string {
-	return columnLabel(column.Number)
-}
-
-func columnLabel(n int) string {
-	result := ""
-	for n >= 0 {
-		remainder := n % 26
-		result = fmt.Sprintf("%c", remainder+65) + result
-		n = n/26 - 1
-	}
-	return result
-}
-
-func columnNumber(label string) int {
-	result := 0
-	for _, char := range label {
-		result = result*26 + int(char) - 64
-	}
-	return result - 1
-}
-
-type Row struct {
-	Number int
-}
-
-func (row Row) Label() string {
-	return strconv.Itoa(row.Number)
-}
-
-type Cell struct {
-	Row    int
-	Column int
-
-	Expression,
-	SavedExpression ExpressionNode
-	Value,
-	SavedValue int
-
-	References []CellIdentifier
-
-	input,
-	Error string
-}
-
-func (cell *Cell) ExpressionText() string {
-	if cell.Expression != nil && cell.Error == "" {
-		return cell.Expression.String()
-	}
-	return cell.input
-}
-
-type EncodedCell struct {
-	ID         string `json:"id"`
-	Expression string `json:"ex"`
-}
-
-func (cell *Cell) MarshalJSON() ([]byte, error) {
-	return json.Marshal(EncodedCell{
-		ID:         strings.TrimPrefix(cell.ID(), "cell-"),
-		Expression: cell.SavedExpression.String(),
-	})
-}
-
-type EncodedTable struct {
-	ColumnCount int           `json:"columns"`
-	RowCount    int           `json:"rows"`
-	Cells       []EncodedCell `json:"cells"`
-}
-
-func (table *Table) UnmarshalJSON(in []byte) error {
-	var encoded EncodedTable
-
-	if err := json.Unmarshal(in, &encoded); err != nil {
-		return err
-	}
-	table.RowCount = encoded.RowCount
-	table.ColumnCount = encoded.ColumnCount
-	for _, cell := range encoded.Cells {
-		column, row, err := parseCellID(cell.ID, table.ColumnCount-1, table.RowCount-1)
-		if err != nil {
-			return err
-		}
-		exp, refs, err := newExpression(cell.Expression, table.ColumnCount-1, table.RowCount-1)
-		if err != nil {
-			return err
-		}
-		table.Cells = append(table.Cells, Cell{
-			Column:          column,
-			Row:             row,
-			SavedExpression: exp,
-			Expression:      exp,
-			References:      refs,
-		})
+		edits = append(edits, engine.CellEdit{Column: column, Row: row, Input: value[0]})
 	}
 
-	return table.calculateValues()
-}
-
-func (cell *Cell) String() string {
-	if cell.SavedExpression == nil {
-		return ""
-	}
-	return strconv.Itoa(cell.Value)
-}
-
-func (cell *Cell) IDPathParam() string {
-	return fmt.Sprintf("%s%d", columnLabel(cell.Column), cell.Row)
-}
-
-func (cell *Cell) ID() string {
-	return "cell-" + cell.IDPathParam()
-}
+	edit, affected := server.workbook.EditCells(sheet, edits)
+	server.commitCells(req, edit, affected)
 
-type Table struct {
-	ColumnCount int    `json:"columns"`
-	RowCount    int    `json:"rows"`
-	Cells       []Cell `json:"cells"`
-}
-
-func NewTable(columns, rows int) Table {
-	table := Table{
-		RowCount:    rows,
-		ColumnCount: columns,
-	}
-	return table
-}
-
-func (table *Table) Cell(column, row int) *Cell {
-	for i, cell := range table.Cells {
-		if cell.Row == row && cell.Column == column {
-			return &table.Cells[i]
-		}
-	}
-	return &Cell{
-		Row:    row,
-		Column: column,
-	}
-}
-
-func (table *Table) Rows() []Row {
-	result := make([]Row, table.RowCount)
-	for i := range result {
-		result[i].Number = i
-	}
-	return result
-}
-
-func (table *Table) Columns() []Column {
-	result := make([]Column, table.ColumnCount)
-	for i := range result {
-		result[i].Number = i
-	}
-	return result
-}
-
-func (table *Table) calculateValues() error {
-	for _, cell := range table.Cells {
-		if cell.Error != "" {
-			return fmt.Errorf("cell parsing error %s", cell.IDPathParam())
-		}
-	}
-	for i := range table.Cells {
-		visited := newWalkState(len(table.Cells))
-		cell := &table.Cells[i]
-		err := cell.evaluate(table, visited)
-		if err != nil {
-			cell.Error = err.Error()
-			table.revertCellChanges()
-			return err
-		}
-		cell.Error = ""
-	}
-	table.saveCellChanges()
-	return nil
+	server.renderTable(res, req, sheet)
 }
 
-var identifierPattern = regexp.MustCompile("(?P<column>[A-Z]+)(?P<row>[0-9]+)")
+// patchCell sets the expression of a single cell from its cell-{id} form
+// field. It responds with out of band swaps of the cell and of the cells of
+// the sheet whose value or error changed so the rest of the table is not
+// rendered again.
+func (server *server) patchCell(res http.ResponseWriter, req *http.Request) {
+	server.mut.Lock()
+	defer server.mut.Unlock()
 
-func parseCellID(in string, maxColumn, maxRow int) (int, int, error) {
-	in = strings.TrimPrefix(in, "cell-")
-	if !identifierPattern.MatchString(in) {
-		return 0, 0, fmt.Errorf("unexpected identifier pattern expected something like A4")
+	if err := req.ParseForm(); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
 	}
-	parts := identifierPattern.FindStringSubmatch(in)
-	columnName := parts[identifierPattern.SubexpIndex("column")]
-	row, err := strconv.Atoi(parts[identifierPattern.SubexpIndex("row")])
+	sheet, err := server.sheet(req)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to parse row number: %w", err)
-	}
-	if row > maxRow {
-		return 0, 0, fmt.Errorf("row number %d out of range it must be greater than 0 and less than or equal to %d", row, maxRow)
-	}
-	column := columnNumber(columnName)
-	if column > maxColumn {
-		return 0, 0, fmt.Errorf("column %s out of range it must be greater than or equal to %s and less than or equal to %s", columnName, columnLabel(0), columnLabel(maxColumn))
-	}
-	return column, row, nil
-}
-
-func (table *Table) saveCellChanges() {
-	for i := range table.Cells {
-		table.Cells[i].SavedValue = table.Cells[i].Value
-		table.Cells[i].SavedExpression = table.Cells[i].Expression
-	}
-}
-
-func (table *Table) revertCellChanges() {
-	for i := range table.Cells {
-		table.Cells[i].Value = table.Cells[i].SavedValue
-		table.Cells[i].Expression = table.Cells[i].SavedExpression
-	}
-}
-
-type Token struct {
-	Type  TokenType
-	Value string
-	Index int
-}
-
-func (token Token) BinaryOpLess(other Token) bool {
-	return token.Type < other.Type
-}
-
-type TokenType int
-
-const (
-	TokenNumber TokenType = iota
-	TokenAdd
-	TokenSubtract
-	TokenMultiply
-	TokenDivide
-	TokenExponent
-	TokenExclamation
-	TokenLeftParenthesis
-	TokenRightParenthesis
-	TokenIdentifier
-)
-
-func tokenize(input string) ([]Token, error) {
-	var tokens []Token
-
-	for i := 0; i < len(input); i++ {
-		c := rune(input[i])
-
-		if unicode.IsDigit(c) {
-			start := i
-			dotCount := 0
-			for i < len(input) && (unicode.IsDigit(rune(input[i])) || (dotCount == 0 && input[i] == '.')) {
-				if input[i] == '.' {
-					dotCount++
-				}
-				i++
-			}
-			tokens = append(tokens, Token{Index: start, Type: TokenNumber, Value: input[start:i]})
-			i--
-		} else if c == '+' {
-			tokens = append(tokens, Token{Index: i, Type: TokenAdd, Value: "+"})
-		} else if c == '!' {
-			tokens = append(tokens, Token{Index: i, Type: TokenExclamation, Value: "!"})
-		} else if c == '-' {
-			tokens = append(tokens, Token{Index: i, Type: TokenSubtract, Value: "-"})
-		} else if c == '*' {
-			tokens = append(tokens, Token{Index: i, Type: TokenMultiply, Value: "*"})
-		} else if c == '/' {
-			tokens = append(tokens, Token{Index: i, Type: TokenDivide, Value: "/"})
-		} else if c == '^' {
-			tokens = append(tokens, Token{Index: i, Type: TokenExponent, Value: "^"})
-		} else if c == '(' {
-			tokens = append(tokens, Token{Index: i, Type: TokenLeftParenthesis, Value: "("})
-		} else if c == ')' {
-			tokens = append(tokens, Token{Index: i, Type: TokenRightParenthesis, Value: ")"})
-		} else if unicode.IsSpace(c) {
-			continue
-		} else if unicode.IsLetter(rune(input[i])) {
-			start := i
-			for i < len(input) && (rune(input[i]) == '_' || unicode.IsLetter(rune(input[i])) || unicode.IsDigit(rune(input[i]))) {
-				i++
-			}
-			tokens = append(tokens, Token{Index: start, Type: TokenIdentifier, Value: input[start:i]})
-			i--
-		}
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
 	}
-
-	return tokens, nil
-}
-
-type ExpressionNode interface {
-	fmt.Stringer
-}
-
-func newExpression(in string, maxColumn, maxRow int) (ExpressionNode, []CellIdentifier, error) {
-	expressionText := normalizeExpression(in)
-	tokens, err := tokenize(expressionText)
+	column, row, err := engine.ParseCellID(req.PathValue("id"), sheet.ColumnCount-1, sheet.RowCount-1)
 	if err != nil {
-		return nil, nil, err
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
 	}
-	expression, references, _, err := parse(tokens, 0, maxColumn, maxRow)
-	if err != nil {
-		return nil, nil, err
+	name := cellView{Cell: sheet.Cell(column, row)}.ID()
+	values, ok := req.Form[name]
+	if !ok {
+		http.Error(res, fmt.Sprintf("form field %s is missing", name), http.StatusBadRequest)
+		return
 	}
-	return expression, references, nil
-}
-
-type IdentifierNode struct {
-	Token Token
-
-	Row, Column int
-}
 
-func (node IdentifierNode) String() string {
-	return node.Token.Value
-}
-
-type IntegerNode struct {
-	Token Token
-	Value int
-}
-
-func (node IntegerNode) String() string {
-	return node.Token.Value
-}
-
-type BinaryExpressionNode struct {
-	Op          Token
-	Left, Right ExpressionNode
-}
-
-func (node BinaryExpressionNode) String() string {
-	return fmt.Sprintf("%s %s %s", node.Left.String(), node.Op.Value, node.Right.String())
-}
-
-type VariableNode struct {
-	Identifier Token
-}
-
-func (node VariableNode) String() string {
-	return fmt.Sprintf("%s", node.Identifier.Value)
-}
-
-type FactorialNode struct {
-	Expression ExpressionNode
-}
-
-func (node FactorialNode) String() string {
-	return fmt.Sprintf("%s!", node.Expression)
-}
-
-type ParenNode struct {
-	Start, End Token
-	Node       ExpressionNode
-}
-
-func (node ParenNode) String() string {
-	return fmt.Sprintf("(%s)", node.Node)
-}
-
-func parse(tokens []Token, i, maxColumn, maxRow int) (ExpressionNode, []CellIdentifier, int, error) {
-	var (
-		stack      []ExpressionNode
-		references []CellIdentifier
-	)
-	for {
-		result, refs, consumed, err := parseNodes(stack, tokens, i, maxColumn, maxRow)
-		if err != nil {
-			return nil, references, consumed + i, err
-		}
-		references = append(references, refs...)
-		i += consumed
-		stack = result
-		if i < len(tokens) {
-			continue
-		}
-		if len(stack) < 1 {
-			return nil, references, i, fmt.Errorf("parsing failed to return an expression")
+	id := sheet.CellID(column, row)
+	before := make(map[engine.CellIdentifier]*engine.Cell)
+	for affected := range server.workbook.AffectedCells([]engine.CellIdentifier{id}) {
+		if affected.Sheet() == sheet.Key() && affected != id {
+			c := *sheet.Cell(affected.Column(), affected.Row())
+			before[affected] = &c
 		}
-		if len(stack) > 1 {
-			return nil, references, i, fmt.Errorf("failed build parse tree multiple %d nodes still on stack: %#v", len(stack)-1, stack)
-		}
-		return stack[0], references, i, nil
 	}
-}
 
-func parseNodes(stack []ExpressionNode, tokens []Token, i, maxColumn, maxRow int) ([]ExpressionNode, []CellIdentifier, int, error) {
-	if i >= len(tokens) {
-		return nil, nil, i, nil
-	}
-
-	token := tokens[i]
+	edit, affected := server.workbook.EditCells(sheet, []engine.CellEdit{{Column: column, Row: row, Input: values[0]}})
+	server.commitCells(req, edit, affected)
 
-	switch token.Type {
-	case TokenNumber:
-		n, err := strconv.Atoi(token.Value)
-		if err != nil {
-			return nil, nil, 1, fmt.Errorf("failed to parse number  %s at expression offset %d: %w", token.Value, token.Index, err)
-		}
-		return append(stack, IntegerNode{Token: token, Value: n}), nil, 1, nil
-	case TokenIdentifier:
-		switch token.Value {
-		case RowIdent, ColumnIdent, MaxRowIdent, MaxColumnIdent, MinRowIdent, MinColumnIdent:
-			return append(stack, VariableNode{Identifier: token}), nil, 1, nil
-		default:
-			column, row, err := parseCellID(token.Value, maxColumn, maxRow)
-			if err != nil {
-				return nil, nil, 0, err
-			}
-			return append(stack, IdentifierNode{Token: token, Row: row, Column: column}), []CellIdentifier{{row: row, column: column}}, 1, nil
-		}
-	case TokenLeftParenthesis:
-		var (
-			totalConsumed = 1
-			parenStack    []ExpressionNode
-		)
-		i += 1
-		for {
-			result, refs, consumed, err := parseNodes(parenStack, tokens, i, maxColumn, maxRow)
-			if err != nil {
-				return nil, nil, 0, err
-			}
-			totalConsumed += consumed
-			i += consumed
-			if i >= len(tokens) {
-				return nil, refs, 0, fmt.Errorf("parenthesis at expression offset %d is missing closing parenthesis", token.Index)
-			}
-			if tokens[i].Type != TokenRightParenthesis {
-				parenStack = result
-				continue
-			}
-			if len(result) == 0 {
-				return nil, refs, 0, fmt.Errorf("parentheses expression is empty")
-			}
-			return append(stack, ParenNode{
-				Node: result[0],
-			}), refs, totalConsumed + 1, nil
-		}
-	case TokenExclamation:
-		if len(stack) == 0 {
-			return nil, nil, 0, fmt.Errorf("malformed factorial expression")
-		}
-		top := stack[len(stack)-1]
-		stack = stack[:len(stack)-1]
-
-		if b, ok := top.(BinaryExpressionNode); ok {
-			if b.Op.BinaryOpLess(token) {
-				return append(stack, BinaryExpressionNode{
-					Op:   b.Op,
-					Left: b.Left,
-					Right: FactorialNode{
-						Expression: b.Right,
-					},
-				}), nil, 1, nil
-			}
+	cells := []*engine.Cell{sheet.Cell(column, row)}
+	for _, affected := range slices.SortedFunc(maps.Keys(before), engine.CompareCellIdentifiers) {
+		c := sheet.Cell(affected.Column(), affected.Row())
+		if !engine.EqualValues(c.Value, before[affected].Value) || c.Error != before[affected].Error {
+			cells = append(cells, c)
 		}
-
-		stack = append(stack, FactorialNode{
-			Expression: top,
-		})
-		return stack, nil, 1, nil
-	case TokenAdd, TokenSubtract, TokenMultiply, TokenDivide, TokenExponent:
-		node := BinaryExpressionNode{
-			Op: token,
-		}
-
-		if len(stack) == 0 {
-			if token.Type != TokenSubtract {
-				return stack, nil, 0, fmt.Errorf("binary expression for operator at index %d missing left hand side", token.Index)
-			}
-			node.Left = IntegerNode{Value: 0}
-		} else {
-			node.Left = stack[len(stack)-1]
-			stack = stack[:len(stack)-1]
-		}
-
-		rightExpression, refs, consumed, err := parseNodes(nil, tokens, i+1, maxColumn, maxRow)
-		if err != nil {
-			return nil, refs, 1 + consumed, err
-		}
-		if len(rightExpression) != 1 {
-			return stack, refs, 0, fmt.Errorf("weird right hand expression after operator at offet %d", token.Index)
-		}
-		node.Right = rightExpression[0]
-
-		if leftBinNode, ok := node.Left.(BinaryExpressionNode); ok {
-			if leftBinNode.Op.BinaryOpLess(node.Op) {
-				leftLeft := leftBinNode.Left
-				leftRight := leftBinNode.Right
-				rightNode := node.Right
-
-				return append(stack, BinaryExpressionNode{
-					Op:   leftBinNode.Op,
-					Left: leftLeft,
-					Right: BinaryExpressionNode{
-						Op:    token,
-						Left:  leftRight,
-						Right: rightNode,
-					},
-				}), nil, 1 + consumed, nil
-			}
-		}
-
-		return append(stack, node), nil, 1 + consumed, nil
-	case TokenRightParenthesis:
-		return nil, nil, 0, fmt.Errorf("unexpected right parenthesis at expression offest %d", token.Index)
 	}
-
-	return nil, nil, 0, nil
+	var buf bytes.Buffer
+	if err := server.executeCells(&buf, cells); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set("content-type", "text/html; charset=utf-8")
+	res.WriteHeader(http.StatusOK)
+	_, _ = res.Write(buf.Bytes())
 }
 
-type CellIdentifier struct {
-	column, row int
+// commitCells records an edit of cells so it can be undone, sends the
+// calculated cells to the other browsers and saves the workbook.
+func (server *server) commitCells(req *http.Request, edit *engine.Change, affected map[engine.CellIdentifier]bool) {
+	server.publishCells(req, affected)
+	server.history.record(edit)
+	server.autosave()
 }
 
-type walkState struct {
-	temporal  []bool
-	permanent []bool
-	indexes   map[CellIdentifier]int
+type Column struct {
+	Number int
 }
 
-func newWalkState(n int) walkState {
-	states := make([]bool, 2*n)
-	return walkState{
-		temporal:  states[:n],
-		permanent: states[n:],
-		indexes:   make(map[CellIdentifier]int),
-	}
+func (column Column) Label() string {
+	return engine.ColumnLabel(column.Number)
 }
 
-func (cell *Cell) evaluate(table *Table, state walkState) error {
-	cid := CellIdentifier{column: cell.Column, row: cell.Row}
-	index := state.indexes[cid]
-	if state.permanent[index] {
-		return nil
-	}
-	if state.temporal[index] {
-		return fmt.Errorf("recursive reference to %s%d", columnLabel(cell.Column), cell.Row)
-	}
-	state.temporal[index] = true
-	if cell.Expression == nil {
-		cell.Value = 0
-		return nil
-	}
-	result, err := evaluate(table, cell, state, cell.Expression)
-	if err != nil {
-		return err
-	}
-	state.permanent[index] = true
-	cell.Value = result
-	return nil
+type Row struct {
+	Number int
 }
 
-const (
-	RowIdent       = "ROW"
-	ColumnIdent    = "COLUMN"
-	MaxRowIdent    = "MAX_ROW"
-	MaxColumnIdent = "MAX_COLUMN"
-	MinRowIdent    = "MIN_ROW"
-	MinColumnIdent = "MIN_COLUMN"
-)
-
-func evaluate(table *Table, cell *Cell, state walkState, expressionNode ExpressionNode) (int, error) {
-	switch node := expressionNode.(type) {
-	case IdentifierNode:
-		cell := table.Cell(node.Column, node.Row)
-		err := cell.evaluate(table, state)
-		return cell.Value, err
-	case IntegerNode:
-		return node.Value, nil
-	case ParenNode:
-		return evaluate(table, cell, state, node.Node)
-	case VariableNode:
-		switch node.Identifier.Value {
-		case RowIdent:
-			return cell.Row, nil
-		case ColumnIdent:
-			return cell.Column, nil
-		case MaxRowIdent:
-			return table.RowCount - 1, nil
-		case MaxColumnIdent:
-			return table.ColumnCount - 1, nil
-		case MinRowIdent, MinColumnIdent:
-			return 0, nil
-		default:
-			return 0, fmt.Errorf("unknown variable %s", node.Identifier.Value)
-		}
-	case FactorialNode:
-		n, err := evaluate(table, cell, state, node.Expression)
-		if err != nil {
-			return 0, err
-		}
-		if n > 20 {
-			return 0, fmt.Errorf("n! where n > 20 is too large")
-		}
-		for i := n - 1; i >= 2; i-- {
-			n *= i
-		}
-		return n, nil
-	case BinaryExpressionNode:
-		leftResult, err := evaluate(table, cell, state, node.Left)
-		if err != nil {
-			return 0, err
-		}
-		rightResult, err := evaluate(table, cell, state, node.Right)
-		if err != nil {
-			return 0, err
-		}
-		switch node.Op.Type {
-		case TokenAdd:
-			return leftResult + rightResult, nil
-		case TokenSubtract:
-			return leftResult - rightResult, nil
-		case TokenMultiply:
-			return leftResult * rightResult, nil
-		case TokenExponent:
-			res := 1
-			for i := 0; i < rightResult; i++ {
-				res *= leftResult
-			}
-			return res, nil
-		case TokenDivide:
-			if rightResult == 0 {
-				return 0, fmt.Errorf("could not divide by zero")
-			}
-			return leftResult / rightResult, nil
-		default:
-			return 0, fmt.Errorf("unknown binary operator %s", node.Op.Value)
-		}
-	default:
-		return 0, fmt.Errorf("unknown expression node")
-	}
+func (row Row) Label() string {
+	return strconv.Itoa(row.Number)
 }
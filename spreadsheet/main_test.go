@@ -1,140 +1,75 @@
 package main
 
-import "testing"
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
 
-func Test_parse(t *testing.T) {
-	for _, tt := range []struct {
-		Name       string
-		Expression string
-		Result     int
-	}{
-		{
-			Name:       "just 1",
-			Expression: "1",
-			Result:     1,
-		},
-		{
-			Name:       "add",
-			Expression: "1 + 2",
-			Result:     3,
-		},
-		{
-			Name:       "subtract",
-			Expression: "1 - 2",
-			Result:     -1,
-		},
-		{
-			Name:       "multiply",
-			Expression: "2 * 3",
-			Result:     6,
-		},
-		{
-			Name:       "divide",
-			Expression: "6 / 2",
-			Result:     3,
-		},
-		{
-			Name:       "no space",
-			Expression: "8/2",
-			Result:     4,
-		},
-		{
-			Name:       "space around",
-			Expression: " 8/2 ",
-			Result:     4,
-		},
-		{
-			Name:       "cell in cells slice",
-			Expression: "A1",
-			Result:     100,
-		},
-		{
-			Name:       "cell not in cells slice",
-			Expression: "J9",
-			Result:     0,
-		},
-		{
-			Name:       "multiple multiply expressions",
-			Expression: "1 * 2 * 3",
-			Result:     6,
-		},
-		{
-			Name:       "precedence order",
-			Expression: "1 * 2 + 3",
-			Result:     5,
-		},
-		{
-			Name:       "non precedence order",
-			Expression: "1 + 2 * 3",
-			Result:     7,
-		},
-		{
-			Name:       "non precedence order on both sides",
-			Expression: "1 + 2 * 3 + 4",
-			Result:     11,
-		},
-		{
-			Name:       "number in parens",
-			Expression: "(1)",
-			Result:     1,
-		},
-		{
-			Name:       "two sets of parens in middle",
-			Expression: "(1 + 2) * (3 + 4)",
-			Result:     21,
-		},
-		{
-			Name:       "one set of parens with binary op with higher president",
-			Expression: "2 * (3 + 4)",
-			Result:     14,
-		},
-		{
-			Name:       "division has higher president over subtraction",
-			Expression: "100 - 6 / 3",
-			Result:     98,
-		},
-		{
-			Name:       "factorial has higher president over subtraction",
-			Expression: "1 - 3!",
-			Result:     -5,
-		},
-	} {
-		t.Run(tt.Name, func(t *testing.T) {
-			tokens, err := tokenize(tt.Expression)
-			if err != nil {
-				t.Fatal(err)
-			}
-			table := NewTable(10, 10)
-			table.Cells = []Cell{{Column: 0, Row: 1, Value: 100, Expression: IntegerNode{Value: 100}}}
-			exp, _, _, err := parse(tokens, 0, 10-1, 10-1)
-			if err != nil {
-				t.Fatal(err)
-			}
+func TestServer_patchCell(t *testing.T) {
+	s := newTestServer(3, 3)
+	patchCells(t, s, map[string]string{"A0": "1", "A1": "A0 + 1", "A2": "A1 * 0", "B0": "SUM(A0:A1)", "C2": "5"})
 
-			state := newWalkState(len(table.Cells))
+	patchCell := func(path string, form url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := serveForm(t, s, http.MethodPatch, path, form)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+		}
+		return rec
+	}
 
-			cell := Cell{Column: 0, Row: 0}
+	// the other fields of the form are ignored
+	rec := patchCell("/cell/A0", url.Values{"cell-A0": {"2"}, "cell-C2": {"6"}})
+	body := rec.Body.String()
+	if got := strings.Count(body, `hx-swap-oob="true"`); got != 3 {
+		t.Errorf("expected 3 out of band cells got %d: %s", got, body)
+	}
+	for _, want := range []string{`id="cell-A0" hx-swap-oob="true"`, `id="cell-A1" hx-swap-oob="true"`, `id="cell-B0" hx-swap-oob="true"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the response to contain %s: %s", want, body)
+		}
+	}
+	// A2 is recalculated but stays zero
+	for _, unexpected := range []string{"cell-A2", "cell-C2", `id="table"`} {
+		if strings.Contains(body, unexpected) {
+			t.Errorf("expected the response to not contain %s: %s", unexpected, body)
+		}
+	}
+	if got := s.workbook.Sheets()[0].Cell(2, 2).String(); got != "5" {
+		t.Errorf("expected C2 to be left alone got %s", got)
+	}
 
-			value, err := evaluate(&table, &cell, state, exp)
-			if err != nil {
-				t.Fatal(err)
-			}
+	// a cell that does not parse is shown with the error
+	rec = patchCell("/cell/A1", url.Values{"cell-A1": {"A0 +"}})
+	if body := rec.Body.String(); !strings.Contains(body, `<input type="text" name="cell-A1" value="A0 &#43;"`) || strings.Contains(body, "cell-B0") {
+		t.Errorf("expected only the edited cell with the parse error: %s", body)
+	}
 
-			if value != tt.Result {
-				t.Errorf("expected %d but got %d", tt.Result, value)
-			}
-		})
+	// a dependent using a range in IF does not hold the range
+	patchCells(t, s, map[string]string{"C0": "IF(A0, A0:A1, 0)"})
+	if rec := patchCell("/cell/A0", url.Values{"cell-A0": {"3"}}); strings.Contains(rec.Body.String(), "cell-C0") {
+		t.Errorf("expected C0 to stay a #VALUE! error: %s", rec.Body.String())
+	}
+
+	// the edit can be undone like a table edit
+	patchCell("/cell/A0", url.Values{"cell-A0": {"10"}})
+	serveForm(t, s, http.MethodPost, "/undo", nil)
+	if got := s.workbook.Sheets()[0].Cell(0, 0).String(); got != "3" {
+		t.Errorf("expected undo to restore A0 got %s", got)
 	}
-}
 
-func Test_columnLabels(t *testing.T) {
-	for i := 0; i < 1000; i++ {
-		label := columnLabel(i)
-		t.Run(label, func(t *testing.T) {
-			result := columnNumber(label)
-			if result != i {
-				t.Errorf("expected %d got %d", i, result)
-			}
-		})
+	for _, tt := range []struct {
+		Path string
+		Form url.Values
+	}{
+		{Path: "/cell/Z9", Form: url.Values{"cell-Z9": {"1"}}},
+		{Path: "/cell/A0", Form: url.Values{"cell-A1": {"1"}}},
+	} {
+		if rec := serveForm(t, s, http.MethodPatch, tt.Path, tt.Form); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected PATCH %s to be a bad request got %d", tt.Path, rec.Code)
+		}
 	}
 }
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func (server *server) postName(res http.ResponseWriter, req *http.Request) {
+	server.editName(res, req, "")
+}
+
+func (server *server) patchName(res http.ResponseWriter, req *http.Request) {
+	server.editName(res, req, req.PathValue("name"))
+}
+
+func (server *server) editName(res http.ResponseWriter, req *http.Request, previous string) {
+	server.mut.Lock()
+	defer server.mut.Unlock()
+
+	if err := req.ParseForm(); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sheet, err := server.formSheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	c, err := server.workbook.SetName(previous, cmp.Or(req.Form.Get("name"), previous), req.Form.Get("reference"), sheet)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	server.history.record(c)
+	server.autosave()
+	redirect(res, req, sheetPath(sheet))
+}
+
+func (server *server) deleteName(res http.ResponseWriter, req *http.Request) {
+	server.mut.Lock()
+	defer server.mut.Unlock()
+
+	sheet, err := server.formSheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	c, err := server.workbook.DeleteName(req.PathValue("name"))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	server.history.record(c)
+	server.autosave()
+	redirect(res, req, sheetPath(sheet))
+}
+
+// formSheet returns the sheet named by the sheet form value. The names panel
+// sends the sheet being shown so references without a sheet refer to it and
+// the page is loaded again after the edit.
+func (server *server) formSheet(req *http.Request) (*engine.Table, error) {
+	name := req.FormValue("sheet")
+	if name == "" {
+		return server.workbook.Sheets()[0], nil
+	}
+	sheet := server.workbook.Sheet(name)
+	if sheet == nil {
+		return nil, fmt.Errorf("sheet %s not found", name)
+	}
+	return sheet, nil
+}
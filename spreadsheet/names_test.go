@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func TestServer_names(t *testing.T) {
+	s := newTestServer(3, 4)
+	patchCells(t, s, map[string]string{"B1": "100", "B2": "200", "B3": "300", "C2": "0.2"})
+
+	for _, form := range []url.Values{
+		{"name": {"tax_rate"}, "reference": {"C2"}},
+		{"name": {"FIRST_QUARTER"}, "reference": {"B1:B3"}},
+	} {
+		if rec := serveForm(t, s, http.MethodPost, "/names", form); rec.Code != http.StatusSeeOther {
+			t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	patchCells(t, s, map[string]string{"A0": "SUM(First_Quarter) * tax_rate", "A1": "TAX_RATE + 1"})
+
+	sheet := s.workbook.Sheets()[0]
+	if got := sheet.Cell(0, 0); got.String() != "120" || got.SavedExpression.String() != "SUM(FIRST_QUARTER) * TAX_RATE" {
+		t.Errorf("expected A0 to use the names got %s = %s", got.SavedExpression, got.Value)
+	}
+
+	// cells using a name are calculated again when a referenced cell changes
+	patchCells(t, s, map[string]string{"C2": "0.5"})
+	if got := sheet.Cell(0, 0).String(); got != "300" {
+		t.Errorf("expected A0 to follow C2 got %s", got)
+	}
+
+	rec := serveForm(t, s, http.MethodPatch, "/names/TAX_RATE", url.Values{"name": {"RATE"}, "reference": {"C2"}})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := sheet.Cell(0, 0).SavedExpression.String(); got != "SUM(FIRST_QUARTER) * RATE" {
+		t.Errorf("expected the renamed name in the formula got %s", got)
+	}
+	if got := sheet.Cell(0, 1).String(); got != "1.5" {
+		t.Errorf("expected A1 to still use the renamed name got %s", got)
+	}
+
+	rec = serveForm(t, s, http.MethodDelete, "/names/RATE", nil)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := sheet.Cell(0, 0).ErrorKind(); got != string(engine.ErrorName) {
+		t.Errorf("expected a deleted name to be an error got %q", got)
+	}
+
+	serveForm(t, s, http.MethodPost, "/undo", nil)
+	if got := sheet.Cell(0, 0).String(); got != "300" {
+		t.Errorf("expected undo to restore the name got %s", got)
+	}
+
+	for _, tt := range []struct {
+		Form    url.Values
+		Message string
+	}{
+		{Form: url.Values{"name": {"Q1"}, "reference": {"B1:B3"}}, Message: "cell reference"},
+		{Form: url.Values{"name": {"SUM"}, "reference": {"B1"}}, Message: "function"},
+		{Form: url.Values{"name": {"MAX_ROW"}, "reference": {"B1"}}, Message: "variable"},
+		{Form: url.Values{"name": {"FIRST_QUARTER"}, "reference": {"B1"}}, Message: "already exists"},
+		{Form: url.Values{"name": {"TOTAL"}, "reference": {"1 + 2"}}, Message: "cell or a range"},
+		{Form: url.Values{"name": {"TOTAL"}, "reference": {"NOPE!A1"}}, Message: "not found"},
+		{Form: url.Values{"name": {"TOTAL"}, "reference": {"A0:A2000000000"}}, Message: "out of range"},
+		{Form: url.Values{"name": {"TOTAL"}, "reference": {"SHEET1!A0:A2000000000"}}, Message: "outside of sheet"},
+	} {
+		rec := serveForm(t, s, http.MethodPost, "/names", tt.Form)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tt.Message) {
+			t.Errorf("expected %v to fail with %q got %d: %s", tt.Form, tt.Message, rec.Code, rec.Body.String())
+		}
+	}
+}
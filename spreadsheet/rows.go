@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func (server *server) postRow(res http.ResponseWriter, req *http.Request) {
+	server.resize(res, req, engine.RowAxis, 1)
+}
+
+func (server *server) deleteRow(res http.ResponseWriter, req *http.Request) {
+	server.resize(res, req, engine.RowAxis, -1)
+}
+
+func (server *server) postColumn(res http.ResponseWriter, req *http.Request) {
+	server.resize(res, req, engine.ColumnAxis, 1)
+}
+
+func (server *server) deleteColumn(res http.ResponseWriter, req *http.Request) {
+	server.resize(res, req, engine.ColumnAxis, -1)
+}
+
+func (server *server) resize(res http.ResponseWriter, req *http.Request, a engine.Axis, delta int) {
+	server.mut.Lock()
+	defer server.mut.Unlock()
+
+	sheet, err := server.sheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	index, err := strconv.Atoi(req.PathValue("index"))
+	if err != nil {
+		http.Error(res, fmt.Sprintf("failed to parse %s index: %s", a, err), http.StatusBadRequest)
+		return
+	}
+	c, err := server.workbook.Resize(sheet, a, index, delta)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	server.history.record(c)
+	server.autosave()
+
+	server.renderTable(res, req, sheet)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func expectExpressions(t *testing.T, table *engine.Table, expressions map[string]string) {
+	t.Helper()
+	for id, want := range expressions {
+		column, row, err := engine.ParseCellID(id, table.ColumnCount-1, table.RowCount-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		if exp := table.Cell(column, row).SavedExpression; exp != nil {
+			got = exp.String()
+		}
+		if got != want {
+			t.Errorf("expected %s to be %q got %q", id, want, got)
+		}
+	}
+}
+
+func TestServer_insertRow(t *testing.T) {
+	s := newTestServer(2, 3)
+	if _, _, err := s.workbook.AddSheet("Other", 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	patchCells(t, s, map[string]string{"A0": "1", "A1": "2", "A2": "SUM(A0:A1)", "B0": "$A$1 * 10"})
+	other := s.workbook.Sheets()[1]
+	rec := serveForm(t, s, http.MethodPatch, "/sheets/Other/table", map[string][]string{"cell-A0": {"Sheet1!A1 + 1"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = serveForm(t, s, http.MethodPost, "/rows/1", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `id="table"`) {
+		t.Errorf("expected the table to be rendered: %s", rec.Body.String())
+	}
+
+	sheet := s.workbook.Sheets()[0]
+	if sheet.RowCount != 4 {
+		t.Errorf("expected 4 rows got %d", sheet.RowCount)
+	}
+	expectExpressions(t, sheet, map[string]string{
+		"A0": "1",
+		"A1": "",
+		"A2": "2",
+		"A3": "SUM(A0:A2)",
+		"B0": "$A$2 * 10",
+	})
+	if got := sheet.Cell(0, 3).String(); got != "3" {
+		t.Errorf("expected the sum to stay 3 got %s", got)
+	}
+	expectExpressions(t, other, map[string]string{"A0": "SHEET1!A2 + 1"})
+	if got := other.Cell(0, 0).String(); got != "3" {
+		t.Errorf("expected the cross sheet reference to follow the cell got %s", got)
+	}
+
+	serveForm(t, s, http.MethodPost, "/undo", nil)
+	if sheet.RowCount != 3 {
+		t.Errorf("expected undo to remove the row got %d rows", sheet.RowCount)
+	}
+	expectExpressions(t, sheet, map[string]string{"A1": "2", "A2": "SUM(A0:A1)", "B0": "$A$1 * 10"})
+	expectExpressions(t, other, map[string]string{"A0": "SHEET1!A1 + 1"})
+}
+
+func TestServer_deleteColumn(t *testing.T) {
+	s := newTestServer(4, 2)
+	patchCells(t, s, map[string]string{
+		"A0": "1", "B0": "2", "C0": "3",
+		"D0": "SUM(A0:C0)",
+		"D1": "B0 + C0",
+		"A1": "SUM(B0:B0)",
+	})
+
+	rec := serveForm(t, s, http.MethodDelete, "/columns/1", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	sheet := s.workbook.Sheets()[0]
+	if sheet.ColumnCount != 3 {
+		t.Errorf("expected 3 columns got %d", sheet.ColumnCount)
+	}
+	expectExpressions(t, sheet, map[string]string{
+		"A0": "1",
+		"B0": "3",
+		"C0": "SUM(A0:B0)",
+		"C1": "#REF! + B0",
+		"A1": "SUM(#REF!)",
+	})
+	if got := sheet.Cell(2, 0).String(); got != "4" {
+		t.Errorf("expected the sum to shrink to 4 got %s", got)
+	}
+	if got := sheet.Cell(2, 1).ErrorKind(); got != string(engine.ErrorReference) {
+		t.Errorf("expected a reference error got %q", got)
+	}
+}
+
+func TestServer_resize_errors(t *testing.T) {
+	s := newTestServer(1, 2)
+	for _, tt := range []struct {
+		Method, Path string
+	}{
+		{Method: http.MethodPost, Path: "/rows/3"},
+		{Method: http.MethodPost, Path: "/rows/-1"},
+		{Method: http.MethodDelete, Path: "/rows/2"},
+		{Method: http.MethodDelete, Path: "/columns/0"},
+		{Method: http.MethodPost, Path: "/columns/x"},
+	} {
+		if rec := serveForm(t, s, tt.Method, tt.Path, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected %s %s to be a bad request got %d", tt.Method, tt.Path, rec.Code)
+		}
+	}
+	if rec := serveForm(t, s, http.MethodPost, "/rows/2", nil); rec.Code != http.StatusOK {
+		t.Errorf("expected a row to be appended got %d", rec.Code)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func TestServer_patchTable_typeError(t *testing.T) {
+	s := newTestServer(3, 3)
+	patchCells(t, s, map[string]string{"A0": `"label"`, "A1": "1"})
+	patchCells(t, s, map[string]string{"B0": "A0 + A1"})
+
+	cell := s.workbook.Sheets()[0].Cell(1, 0)
+	if got := cell.ErrorKind(); got != string(engine.ErrorValueType) {
+		t.Errorf("expected B0 to be %s got %q", engine.ErrorValueType, got)
+	}
+	if !strings.Contains(cell.ErrorMessage(), "type error") {
+		t.Errorf("expected B0 to describe the type error got %q", cell.ErrorMessage())
+	}
+	if got := s.workbook.Sheets()[0].Cell(0, 0).String(); got != "label" {
+		t.Errorf("expected A0 to keep its value got %q", got)
+	}
+}
+
+func TestServer_patchTable_errorValues(t *testing.T) {
+	s := newTestServer(3, 3)
+	patchCells(t, s, map[string]string{"A0": "1", "A1": "0", "B0": "A0 / A1", "B1": "B0 + 1", "C0": "A0 + 1"})
+
+	for _, id := range []string{"B0", "B1"} {
+		column, row, _ := engine.ParseCellID(id, 2, 2)
+		if got := s.workbook.Sheets()[0].Cell(column, row).ErrorKind(); got != string(engine.ErrorDivideByZero) {
+			t.Errorf("expected %s to be %s got %q", id, engine.ErrorDivideByZero, got)
+		}
+	}
+	if got := s.workbook.Sheets()[0].Cell(2, 0).Value; got != engine.NewNumber(2) {
+		t.Errorf("expected C0 to be calculated got %s", got)
+	}
+
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `class="cell error" id="cell-B1"`) || !strings.Contains(body, ">#DIV/0!</td>") {
+		t.Errorf("expected the error kind to be rendered got %s", body)
+	}
+
+	patchCells(t, s, map[string]string{"A1": "4"})
+	if got := s.workbook.Sheets()[0].Cell(1, 1).String(); got != "1.25" {
+		t.Errorf("expected B1 to recover got %s", got)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+const (
+	// rowWindow is the number of rows rendered at once. More rows are
+	// loaded when the last one is scrolled into view.
+	rowWindow = 50
+
+	// columnWindow is the number of columns rendered at once.
+	columnWindow = 26
+
+	// cellIDPrefix starts the element id and the form field name of a cell.
+	cellIDPrefix = "cell-"
+)
+
+// cellView is a cell as it is rendered. SwapOOB is set when it replaces the
+// element of the cell out of band.
+type cellView struct {
+	*engine.Cell
+	SwapOOB bool
+}
+
+// ID returns the element id of the cell, like cell-B3.
+func (cell cellView) ID() string {
+	return cellIDPrefix + cell.Label()
+}
+
+// sheetView is the window of a sheet shown in the browser. The rows from
+// FirstRow up to EndRow and the columnWindow columns from FirstColumn are
+// rendered so a page only holds the cells it shows, not every cell of the
+// sheet.
+type sheetView struct {
+	*engine.Table
+	FirstRow, EndRow, FirstColumn int
+}
+
+// newSheetView returns the window starting at row and column with at least
+// rowWindow rows, or the rows up to end when that is further down. It is
+// moved into the sheet when the sheet got smaller.
+func newSheetView(sheet *engine.Table, row, column, end int) sheetView {
+	row = max(min(row, sheet.RowCount-1), 0)
+	column = max(min(column, sheet.ColumnCount-1), 0)
+	end = min(max(end, row+rowWindow), sheet.RowCount)
+	return sheetView{Table: sheet, FirstRow: row, EndRow: end, FirstColumn: column}
+}
+
+// view returns the window of the page that sent the request. The table
+// form keeps it in the window-row, window-column and window-end fields so
+// it stays the same when the table is rendered again.
+func (server *server) view(req *http.Request, sheet *engine.Table) sheetView {
+	return newSheetView(sheet, formInt(req, "window-row"), formInt(req, "window-column"), formInt(req, "window-end"))
+}
+
+// formInt returns the form value as an int. A missing or malformed value
+// is zero.
+func formInt(req *http.Request, name string) int {
+	n, _ := strconv.Atoi(req.FormValue(name))
+	return n
+}
+
+// Rows returns the rows of the window.
+func (view sheetView) Rows() []Row {
+	result := make([]Row, 0, max(view.EndRow-view.FirstRow, 0))
+	for row := view.FirstRow; row < view.EndRow; row++ {
+		result = append(result, Row{Number: row})
+	}
+	return result
+}
+
+// Columns returns the columns of the window.
+func (view sheetView) Columns() []Column {
+	end := min(view.FirstColumn+columnWindow, view.ColumnCount)
+	result := make([]Column, 0, max(end-view.FirstColumn, 0))
+	for column := view.FirstColumn; column < end; column++ {
+		result = append(result, Column{Number: column})
+	}
+	return result
+}
+
+// Cell returns the cell at column and row as it is rendered.
+func (view sheetView) Cell(column, row int) cellView {
+	return cellView{Cell: view.Table.Cell(column, row)}
+}
+
+// MoreRows reports whether there are rows after the window.
+func (view sheetView) MoreRows() bool {
+	return view.EndRow < view.RowCount
+}
+
+// PreviousRow is the first row of the window before this one.
+func (view sheetView) PreviousRow() int {
+	return max(view.FirstRow-rowWindow, 0)
+}
+
+// PreviousColumn is the first column of the columns left of the window.
+func (view sheetView) PreviousColumn() int {
+	return max(view.FirstColumn-columnWindow, 0)
+}
+
+// NextColumn is the first column right of the window or zero when the window
+// shows the last column.
+func (view sheetView) NextColumn() int {
+	if next := view.FirstColumn + columnWindow; next < view.ColumnCount {
+		return next
+	}
+	return 0
+}
+
+// renderTable renders the window of the table of the page that sent the
+// request.
+func (server *server) renderTable(res http.ResponseWriter, req *http.Request, sheet *engine.Table) {
+	server.render(res, req, "table", http.StatusOK, server.view(req, sheet))
+}
+
+// getTable renders a window of the table. The row and column query
+// parameters or a cell, like B5000, select the window. Without them the
+// window of the page is rendered again.
+func (server *server) getTable(res http.ResponseWriter, req *http.Request) {
+	server.mut.RLock()
+	defer server.mut.RUnlock()
+
+	sheet, err := server.sheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	query := req.URL.Query()
+	switch {
+	case query.Get("cell") != "":
+		column, row, err := engine.ParseCellID(query.Get("cell"), sheet.ColumnCount-1, sheet.RowCount-1)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		server.render(res, req, "table", http.StatusOK, newSheetView(sheet, row, column-column%columnWindow, 0))
+	case query.Has("row") || query.Has("column"):
+		row, column, err := windowQuery(req)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		server.render(res, req, "table", http.StatusOK, newSheetView(sheet, row, column, 0))
+	default:
+		server.renderTable(res, req, sheet)
+	}
+}
+
+// getRows renders the next rows of a window when the table is scrolled.
+func (server *server) getRows(res http.ResponseWriter, req *http.Request) {
+	server.mut.RLock()
+	defer server.mut.RUnlock()
+
+	sheet, err := server.sheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	row, column, err := windowQuery(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if row >= sheet.RowCount {
+		http.Error(res, "row "+strconv.Itoa(row)+" is out of range", http.StatusBadRequest)
+		return
+	}
+	server.render(res, req, "rows", http.StatusOK, newSheetView(sheet, row, column, 0))
+}
+
+// windowQuery parses the row and column query parameters, missing ones are
+// zero.
+func windowQuery(req *http.Request) (int, int, error) {
+	var result [2]int
+	for i, name := range []string{"row", "column"} {
+		value := req.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return 0, 0, &strconv.NumError{Func: "window " + name, Num: value, Err: strconv.ErrSyntax}
+		}
+		result[i] = n
+	}
+	return result[0], result[1], nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func getBody(t *testing.T, s *server, path string) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	return rec.Body.String()
+}
+
+func TestServer_view(t *testing.T) {
+	s := newTestServer(100, 100_000)
+	patchCells(t, s, map[string]string{"A0": "1", "B60": "A0 + 1", "CV99999": "42"})
+
+	body := getBody(t, s, "/")
+	if got := strings.Count(body, `class="cell`); got != rowWindow*columnWindow {
+		t.Errorf("expected only the cells of the window to be rendered got %d", got)
+	}
+	for _, want := range []string{
+		`id="cell-Z49"`,
+		`<tr id="more-rows" hx-get="rows?row=50&column=0" hx-trigger="revealed" hx-swap="outerHTML">`,
+		`<input type="hidden" name="window-end" value="50">`,
+		`hx-get="table?row=0&column=26"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the page to contain %q", want)
+		}
+	}
+	for _, unexpected := range []string{`id="cell-AA0"`, `id="cell-A50"`, "show the previous"} {
+		if strings.Contains(body, unexpected) {
+			t.Errorf("expected the page not to contain %q", unexpected)
+		}
+	}
+
+	// scrolling to the last row loads the next rows
+	body = getBody(t, s, "/rows?row=50&column=0")
+	if got := strings.Count(body, "<tr"); got != rowWindow+1 {
+		t.Errorf("expected the next rows and the row loading more got %d", got)
+	}
+	for _, want := range []string{`id="cell-B60"`, ">2</td>", `name="window-end" value="100"`, `hx-get="rows?row=100&column=0"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the rows to contain %q", want)
+		}
+	}
+
+	// jumping to a cell shows the window with the cell in the first row
+	body = getBody(t, s, "/table?cell=CV99999")
+	for _, want := range []string{`id="cell-CV99999"`, ">42</td>", `name="window-row" value="99999"`, `name="window-column" value="78"`, "show the previous columns"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the table to contain %q", want)
+		}
+	}
+	if strings.Contains(body, "hx-trigger=\"revealed\"") {
+		t.Error("expected the last row not to load more rows")
+	}
+	if strings.Contains(body, "show the next columns") {
+		t.Error("expected no next columns after the last column")
+	}
+
+	for _, path := range []string{"/table?cell=XYZ", "/table?row=-1", "/rows?row=100000", "/rows?column=x"} {
+		rec := httptest.NewRecorder()
+		s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expected %s to be a bad request got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestServer_view_keptAfterEdit(t *testing.T) {
+	s := newTestServer(30, 200)
+	rec := serveForm(t, s, http.MethodPatch, "/table", url.Values{
+		"window-row":    {"100"},
+		"window-column": {"26"},
+		"window-end":    {"180"},
+		"cell-AB150":    {"7"},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	if got := strings.Count(body, `class="cell`); got != 80*4 {
+		t.Errorf("expected the loaded rows of the window to be rendered again got %d cells", got)
+	}
+	for _, want := range []string{`name="window-row" value="100"`, `id="cell-AB150"`, ">7</td>", `hx-get="rows?row=180&column=26"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the table to contain %q", want)
+		}
+	}
+
+	// the window is moved into a sheet that got smaller
+	if got := newSheetView(s.workbook.Sheets()[0], 500, 40, 0); got.FirstRow != 199 || got.EndRow != 200 || got.FirstColumn != 29 {
+		t.Errorf("unexpected window %+v", got)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+// sheet returns the sheet named by the sheet path parameter. Routes without
+// the parameter use the first sheet.
+func (server *server) sheet(req *http.Request) (*engine.Table, error) {
+	name := req.PathValue("sheet")
+	if name == "" {
+		return server.workbook.Sheets()[0], nil
+	}
+	sheet := server.workbook.Sheet(name)
+	if sheet == nil {
+		return nil, fmt.Errorf("sheet %s not found", name)
+	}
+	return sheet, nil
+}
+
+func (server *server) postSheet(res http.ResponseWriter, req *http.Request) {
+	server.mut.Lock()
+	defer server.mut.Unlock()
+
+	if err := req.ParseForm(); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	first := server.workbook.Sheets()[0]
+	columns, rows := first.ColumnCount, first.RowCount
+	for _, size := range []struct {
+		name  string
+		value *int
+	}{
+		{name: "columns", value: &columns},
+		{name: "rows", value: &rows},
+	} {
+		if value := req.Form.Get(size.name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(res, fmt.Sprintf("failed to parse %s: %s", size.name, err), http.StatusBadRequest)
+				return
+			}
+			*size.value = n
+		}
+	}
+	sheet, c, err := server.workbook.AddSheet(req.Form.Get("name"), columns, rows)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	server.history.record(c)
+	server.autosave()
+	redirect(res, req, sheetPath(sheet))
+}
+
+func (server *server) patchSheet(res http.ResponseWriter, req *http.Request) {
+	server.mut.Lock()
+	defer server.mut.Unlock()
+
+	if err := req.ParseForm(); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sheet, err := server.sheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	c, err := server.workbook.RenameSheet(sheet.Name, req.Form.Get("name"))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	server.history.record(c)
+	server.autosave()
+	redirect(res, req, sheetPath(sheet))
+}
+
+func (server *server) deleteSheet(res http.ResponseWriter, req *http.Request) {
+	server.mut.Lock()
+	defer server.mut.Unlock()
+
+	sheet, err := server.sheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	c, err := server.workbook.DeleteSheet(sheet.Name)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	server.history.record(c)
+	server.autosave()
+	redirect(res, req, "/")
+}
+
+func sheetPath(sheet *engine.Table) string {
+	return "/sheets/" + sheet.Name + "/"
+}
+
+// redirect navigates to the url. Requests made by htmx get the HX-Redirect
+// header, other requests a See Other redirect.
+func redirect(res http.ResponseWriter, req *http.Request, url string) {
+	if req.Header.Get("HX-Request") == "true" {
+		res.Header().Set("HX-Redirect", url)
+		res.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(res, req, url, http.StatusSeeOther)
+}
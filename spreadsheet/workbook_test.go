@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func serveForm(t *testing.T, s *server, method, path string, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	req.Header.Set("content-type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_sheets(t *testing.T) {
+	s := newTestServer(3, 3)
+
+	rec := serveForm(t, s, http.MethodPost, "/sheets", url.Values{"name": {"Data"}, "rows": {"5"}})
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("location") != "/sheets/Data/" {
+		t.Fatalf("expected a redirect to the new sheet got %d %q", rec.Code, rec.Header().Get("location"))
+	}
+	data := s.workbook.Sheet("data")
+	if data == nil || data.ColumnCount != 3 || data.RowCount != 5 {
+		t.Fatalf("expected a 3 by 5 sheet named Data")
+	}
+
+	if rec := serveForm(t, s, http.MethodPatch, "/sheets/Data/table", url.Values{"cell-A4": {"5"}}); rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	patchCells(t, s, map[string]string{"A0": "data!A4 * 2", "B0": "SUM(DATA!A0:A4)", "C0": "DATA!C9"})
+	first := s.workbook.Sheets()[0]
+	if got := first.Cell(0, 0).Value; got != engine.NewNumber(10) {
+		t.Errorf("expected A0 to be 10 got %s", got)
+	}
+	if got := first.Cell(2, 0).ErrorKind(); got != string(engine.ErrorReference) {
+		t.Errorf("expected a reference outside of the other sheet to be %s got %q", engine.ErrorReference, got)
+	}
+
+	serveForm(t, s, http.MethodPatch, "/sheets/Data/table", url.Values{"cell-A4": {"7"}})
+	if got := first.Cell(0, 0).Value; got != engine.NewNumber(14) {
+		t.Errorf("expected the change on the other sheet to recalculate A0 to 14 got %s", got)
+	}
+	if got := first.Cell(1, 0).Value; got != engine.NewNumber(7) {
+		t.Errorf("expected B0 to be 7 got %s", got)
+	}
+
+	rec = serveForm(t, s, http.MethodPatch, "/sheets/Data", url.Values{"name": {"Inputs"}})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := first.Cell(0, 0).SavedExpression.String(); got != "INPUTS!A4 * 2" {
+		t.Errorf("expected the reference to be renamed got %s", got)
+	}
+	if got := first.Cell(0, 0).Value; got != engine.NewNumber(14) {
+		t.Errorf("expected A0 to still be 14 got %s", got)
+	}
+
+	rec = serveForm(t, s, http.MethodPost, "/sheets", url.Values{"name": {"inputs"}})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a duplicate sheet name to be rejected got %d", rec.Code)
+	}
+
+	rec = serveForm(t, s, http.MethodDelete, "/sheets/Inputs", nil)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := first.Cell(0, 0).ErrorKind(); got != string(engine.ErrorReference) {
+		t.Errorf("expected a reference to the deleted sheet to be %s got %q", engine.ErrorReference, got)
+	}
+
+	rec = serveForm(t, s, http.MethodDelete, "/sheets/Sheet1", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected deleting the last sheet to fail got %d", rec.Code)
+	}
+
+	rec = serveForm(t, s, http.MethodGet, "/sheets/Missing/", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected an unknown sheet to not be found got %d", rec.Code)
+	}
+}
+
+func TestServer_sheetPage(t *testing.T) {
+	s := newTestServer(2, 2)
+	if _, _, err := s.workbook.AddSheet("Totals", 2, 2); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/sheets/Totals/", nil)
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, req)
+	body := rec.Body.String()
+	if !strings.Contains(body, `<a href="/sheets/Totals/" aria-current="page">Totals</a>`) {
+		t.Errorf("expected the sheet to be marked as current got %s", body)
+	}
+	if !strings.Contains(body, `hx-get="cell/A0"`) {
+		t.Errorf("expected cell URLs to be relative to the sheet page got %s", body)
+	}
+
+	rec = serveForm(t, s, http.MethodPost, "/sheets", url.Values{"name": {"my sheet"}})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a sheet name with a space to be rejected got %d", rec.Code)
+	}
+	req = httptest.NewRequest(http.MethodDelete, "/sheets/Totals", nil)
+	req.Header.Set("HX-Request", "true")
+	rec = httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, req)
+	if rec.Header().Get("HX-Redirect") != "/" {
+		t.Errorf("expected htmx requests to be redirected with a header")
+	}
+}
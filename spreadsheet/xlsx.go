@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func (server *server) getTableXLSX(res http.ResponseWriter, req *http.Request) {
+	server.mut.RLock()
+	defer server.mut.RUnlock()
+
+	sheet, err := server.sheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	var buf bytes.Buffer
+	if err := sheet.WriteXLSX(&buf); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h := res.Header()
+	h.Set("content-type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	h.Set("content-length", strconv.Itoa(buf.Len()))
+	res.WriteHeader(http.StatusOK)
+	_, _ = res.Write(buf.Bytes())
+}
+
+// postTableXLSX replaces the table with an uploaded workbook. Like
+// postTableCSV it reads the "table.xlsx" part of a multipart form or the
+// request body.
+func (server *server) postTableXLSX(res http.ResponseWriter, req *http.Request) {
+	body, err := uploadedFile(res, req, "table.xlsx")
+	if err != nil {
+		http.Error(res, err.Error(), uploadErrorStatus(err))
+		return
+	}
+	// the zip directory is at the end of the file
+	buf, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(res, err.Error(), uploadErrorStatus(err))
+		return
+	}
+	table, err := engine.ReadTableXLSX(bytes.NewReader(buf), int64(len(buf)))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	server.mut.Lock()
+	defer server.mut.Unlock()
+	sheet, err := server.sheet(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	server.history.record(server.workbook.ReplaceSheet(sheet, &table))
+	server.autosave()
+
+	server.renderTable(res, req, sheet)
+}
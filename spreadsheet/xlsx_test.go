@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/crhntr/go-htmx-examples/spreadsheet/engine"
+)
+
+func TestServer_tableXLSX(t *testing.T) {
+	fixture, err := os.ReadFile("engine/testdata/table.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(1, 1)
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/table.xlsx", bytes.NewReader(fixture)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := s.workbook.Sheets()[0].Cell(1, 4).String(); got != "1251.05" {
+		t.Errorf("expected B4 to be calculated got %s", got)
+	}
+
+	rec = httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/table.xlsx", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := engine.ReadTableXLSX(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len())); err != nil {
+		t.Errorf("expected a readable workbook: %s", err)
+	}
+
+	rec = httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/table.xlsx", bytes.NewReader([]byte("not a workbook"))))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a bad request got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/table.xlsx", bytes.NewReader(make([]byte, maxUploadSize+1))))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected the upload to be too large got %d", rec.Code)
+	}
+}